@@ -0,0 +1,147 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Builder constructs a MagnetURI one parameter at a time.
+// When the same prefix is added more than once, the parameters with that
+// prefix are numbered from 1 in the order they were added.
+type Builder struct {
+	parameters []Parameter
+}
+
+// NewBuilder returns a Builder without parameters.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// NewKeywordSearch returns a Magnet URI with a single keyword topic
+// parameter, with the terms joined by "+", like "kt=martin+luther+king+mp3".
+// The terms are encoded like the values of NewParameter, but their spaces are
+// written as "%20" so every term is returned as one keyword by Keywords.
+// Empty terms are ignored, and without terms the Magnet URI has no
+// parameters.
+func NewKeywordSearch(terms ...string) MagnetURI {
+	encodedTerms := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+		encodedTerms = append(encodedTerms,
+			encodeValueWith(term, "%20"))
+	}
+	if len(encodedTerms) == 0 {
+		return MagnetURI{}
+	}
+	return MagnetURI{Parameters: []Parameter{
+		{KeywordTopic, 0, strings.Join(encodedTerms, "+")},
+	}}
+}
+
+// AddExactTopic adds an exact topic parameter to the Builder.
+func (builder *Builder) AddExactTopic(value string) *Builder {
+	return builder.add(ExactTopic, value)
+}
+
+// AddDisplayName adds a display name parameter to the Builder.
+// The name is a human readable string, that is encoded like the values of
+// NewParameter, with spaces written as "+".
+func (builder *Builder) AddDisplayName(name string) *Builder {
+	return builder.add(DisplayName, encodeValue(name))
+}
+
+// AddKeywordTopic adds a keyword topic parameter to the Builder.
+// The keywords are a human readable string separated by spaces, that is
+// encoded like the values of NewParameter, so the keywords are written
+// separated by "+" and the reserved characters in them are escaped.
+func (builder *Builder) AddKeywordTopic(keywords string) *Builder {
+	return builder.add(KeywordTopic, encodeValue(keywords))
+}
+
+// AddManifestTopic adds a manifest topic parameter to the Builder.
+func (builder *Builder) AddManifestTopic(value string) *Builder {
+	return builder.add(ManifestTopic, value)
+}
+
+// AddTracker adds an address tracker parameter to the Builder.
+func (builder *Builder) AddTracker(value string) *Builder {
+	return builder.add(AddressTracker, value)
+}
+
+// AddTrackerTier adds an address tracker parameter to the Builder with the
+// tier as its index, so it is written like "tr.1". Tiers start at 1.
+func (builder *Builder) AddTrackerTier(tier int, url string) *Builder {
+	builder.parameters = append(
+		builder.parameters, Parameter{AddressTracker, tier, url})
+	return builder
+}
+
+// AddWebSeed adds a web seed parameter to the Builder.
+func (builder *Builder) AddWebSeed(value string) *Builder {
+	return builder.add(WebSeed, value)
+}
+
+func (builder *Builder) add(prefix Prefix, value string) *Builder {
+	builder.parameters = append(
+		builder.parameters, Parameter{prefix, 0, value})
+	return builder
+}
+
+// Build returns the MagnetURI with the parameters added to the Builder.
+// Parameters added without an index are numbered after the highest index used
+// by their prefix when the prefix is added more than once.
+func (builder *Builder) Build() (MagnetURI, error) {
+	if len(builder.parameters) == 0 {
+		return MagnetURI{}, errors.New("The Magnet URI has no parameters.")
+	}
+	counts := make(map[Prefix]int)
+	maxIndices := make(map[Prefix]int)
+	for _, parameter := range builder.parameters {
+		if parameter.Value == "" {
+			return MagnetURI{}, errors.New(
+				fmt.Sprintf("Empty value for parameter prefix: %q",
+					parameter.Prefix))
+		}
+		if parameter.Index < 0 || parameter.Index >= 1<<maxIndexBits {
+			return MagnetURI{}, errors.New(
+				fmt.Sprintf("Wrong index for parameter %q: %d",
+					parameter.Prefix, parameter.Index))
+		}
+		counts[parameter.Prefix]++
+		if parameter.Index > maxIndices[parameter.Prefix] {
+			maxIndices[parameter.Prefix] = parameter.Index
+		}
+	}
+	parameters := make([]Parameter, 0, len(builder.parameters))
+	for _, parameter := range builder.parameters {
+		if parameter.Index == 0 && counts[parameter.Prefix] > 1 {
+			maxIndices[parameter.Prefix]++
+			parameter.Index = maxIndices[parameter.Prefix]
+			if parameter.Index >= 1<<maxIndexBits {
+				return MagnetURI{}, errors.New(
+					fmt.Sprintf("Wrong index for parameter %q: %d",
+						parameter.Prefix, parameter.Index))
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	return MagnetURI{Parameters: parameters}, nil
+}
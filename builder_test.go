@@ -0,0 +1,249 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	magnetURI, error := NewBuilder().
+		AddExactTopic("urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C").
+		AddDisplayName("I Have A Dream.mp3").
+		AddExactTopic("urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7").
+		AddTracker("http://tracker.example/announce").
+		Build()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedString := "magnet:?" +
+		"xt.1=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+		"dn=I+Have+A+Dream.mp3&" +
+		"xt.2=urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7&" +
+		"tr=http://tracker.example/announce"
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != expectedString {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			expectedString, magnetURIString)
+	}
+}
+
+func TestBuilderWithErrors(t *testing.T) {
+	scenarios := builderWithErrorsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := scenario.Builder.Build()
+		if !magnetURI.Equal(MagnetURI{}) {
+			t.Errorf("Error on test %q: a Magnet URI was returned: %v",
+				scenario.Name, magnetURI)
+		}
+		if error == nil {
+			t.Fatalf("No error was returned on %q test.", scenario.Name)
+		}
+		if error.Error() != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, error.Error())
+		}
+	}
+}
+
+type builderWithErrorsScenario struct {
+	Name          string
+	Builder       *Builder
+	ExpectedError string
+}
+
+var builderWithErrorsScenarios = []builderWithErrorsScenario{
+	{
+		Name:          "Builder without parameters",
+		Builder:       NewBuilder(),
+		ExpectedError: "The Magnet URI has no parameters.",
+	},
+	{
+		Name: "Builder with wrong tracker tier",
+		Builder: NewBuilder().
+			AddExactTopic("urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C").
+			AddTrackerTier(-1, "http://tracker.example/announce"),
+		ExpectedError: "Wrong index for parameter \"tr\": -1",
+	},
+	{
+		Name: "Builder with too large tracker tier",
+		Builder: NewBuilder().
+			AddExactTopic("urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C").
+			AddTrackerTier(1<<40, "http://tracker.example/announce"),
+		ExpectedError: "Wrong index for parameter \"tr\": 1099511627776",
+	},
+	{
+		Name: "Builder numbering past the largest tracker tier",
+		Builder: NewBuilder().
+			AddExactTopic("urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C").
+			AddTrackerTier(1<<31-1, "http://tracker1.example/announce").
+			AddTracker("http://tracker2.example/announce"),
+		ExpectedError: "Wrong index for parameter \"tr\": 2147483648",
+	},
+	{
+		Name: "Builder with empty value",
+		Builder: NewBuilder().
+			AddExactTopic("urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C").
+			AddTracker(""),
+		ExpectedError: "Empty value for parameter prefix: \"tr\"",
+	},
+}
+
+func TestBuilderEncodesDisplayName(t *testing.T) {
+	magnetURI, error := NewBuilder().
+		AddExactTopic("urn:btih:abc").
+		AddDisplayName("Rock & Roll: 100% + more/").
+		Build()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedValue := "Rock+%26+Roll:+100%25+%2B+more/"
+	if value, _ := magnetURI.Get("dn", 0); value != expectedValue {
+		t.Errorf("Expected display name value %q; got %q",
+			expectedValue, value)
+	}
+	displayName, _ := magnetURI.DisplayName()
+	if displayName != "Rock & Roll: 100% + more/" {
+		t.Errorf("Expected display name %q; got %q",
+			"Rock & Roll: 100% + more/", displayName)
+	}
+}
+
+func TestBuilderRoundTripWithReservedCharacters(t *testing.T) {
+	built, error := NewBuilder().
+		AddExactTopic("urn:btih:abc").
+		AddDisplayName("A & B = C #1").
+		AddKeywordTopic("rock&roll 1+1=2").
+		Build()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	builtString, error := built.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	magnetURI, error := Parse(builtString)
+	if error != nil {
+		t.Fatalf("There was an error parsing %q: %q",
+			builtString, error.Error())
+	}
+	if !magnetURI.Equal(built) {
+		t.Errorf("Expected Magnet URI: %v; got %v", built, magnetURI)
+	}
+	displayName, _ := magnetURI.DisplayName()
+	if displayName != "A & B = C #1" {
+		t.Errorf("Expected display name %q; got %q",
+			"A & B = C #1", displayName)
+	}
+	expectedKeywords := []string{"rock&roll", "1+1=2"}
+	keywords := magnetURI.Keywords()
+	if !reflect.DeepEqual(keywords, expectedKeywords) {
+		t.Errorf("Expected keywords %q; got %q", expectedKeywords, keywords)
+	}
+}
+
+func TestBuilderTrackerTiers(t *testing.T) {
+	magnetURI, error := NewBuilder().
+		AddExactTopic("urn:btih:abc").
+		AddTrackerTier(2, "http://tier2.example/announce").
+		AddTrackerTier(1, "http://tier1.example/announce").
+		AddTracker("http://untiered.example/announce").
+		Build()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedString := "magnet:?xt=urn:btih:abc&" +
+		"tr.2=http://tier2.example/announce&" +
+		"tr.1=http://tier1.example/announce&" +
+		"tr.3=http://untiered.example/announce"
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != expectedString {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			expectedString, magnetURIString)
+	}
+}
+
+func TestTrackerTiersRoundTrip(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&" +
+		"tr.1=http://tier1.example/announce&" +
+		"tr.2=http://tier2.example/announce&" +
+		"tr.2=http://tier2-backup.example/announce"
+	magnetURI, error := Parse(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != rawMagnetURI {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			rawMagnetURI, magnetURIString)
+	}
+}
+
+func TestNewKeywordSearch(t *testing.T) {
+	scenarios := newKeywordSearchScenarios
+	for _, scenario := range scenarios {
+		magnetURI := NewKeywordSearch(scenario.Terms...)
+		magnetURIString, _ := magnetURI.String()
+		if magnetURIString != scenario.ExpectedRawMagnetURI {
+			t.Errorf("Error on test %q: expected Magnet URI %q; got %q",
+				scenario.Name, scenario.ExpectedRawMagnetURI,
+				magnetURIString)
+		}
+		keywords := magnetURI.Keywords()
+		if !reflect.DeepEqual(keywords, scenario.ExpectedKeywords) {
+			t.Errorf("Error on test %q: expected keywords %q; got %q",
+				scenario.Name, scenario.ExpectedKeywords, keywords)
+		}
+	}
+}
+
+type newKeywordSearchScenario struct {
+	Name                 string
+	Terms                []string
+	ExpectedRawMagnetURI string
+	ExpectedKeywords     []string
+}
+
+var newKeywordSearchScenarios = []newKeywordSearchScenario{
+	{
+		Name:                 "Overview example 3",
+		Terms:                []string{"martin", "luther", "king", "mp3"},
+		ExpectedRawMagnetURI: "magnet:?kt=martin+luther+king+mp3",
+		ExpectedKeywords:     []string{"martin", "luther", "king", "mp3"},
+	},
+	{
+		Name:                 "Reserved characters and empty terms",
+		Terms:                []string{"", "rock&roll", "1+1=2", "", "new york"},
+		ExpectedRawMagnetURI: "magnet:?kt=rock%26roll+1%2B1%3D2+new%20york",
+		ExpectedKeywords:     []string{"rock&roll", "1+1=2", "new york"},
+	},
+	{
+		Name:             "No terms",
+		Terms:            []string{""},
+		ExpectedKeywords: []string{},
+	},
+}
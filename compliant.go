@@ -0,0 +1,127 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MakeCompliant returns a copy of the Magnet URI cleaned up to follow the
+// specification, together with a description of every change made.
+// Unknown, empty and malformed parameters are dropped, BitTorrent info hashes
+// are written as lowercase hex, values are percent-encoded where needed and
+// indices are renumbered so that a prefix used once has no index and a prefix
+// used several times is numbered from 1.
+// An error is returned only if no valid exact topic remains.
+func (magnetURI *MagnetURI) MakeCompliant() (MagnetURI, []string, error) {
+	var changes []string
+	parameters := make([]Parameter, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		compliantParameter, change, ok := makeParameterCompliant(parameter)
+		if change != "" {
+			changes = append(changes, change)
+		}
+		if ok {
+			parameters = append(parameters, compliantParameter)
+		}
+	}
+	changes = append(changes, renumberParameters(parameters)...)
+	compliantMagnetURI := MagnetURI{Parameters: parameters}
+	if len(compliantMagnetURI.ExactTopics()) == 0 {
+		return MagnetURI{}, changes, errors.New(
+			"The Magnet URI has no valid exact topic")
+	}
+	return compliantMagnetURI, changes, nil
+}
+
+func makeParameterCompliant(parameter Parameter) (Parameter, string, bool) {
+	if !isValidPrefix(parameter.Prefix) {
+		return Parameter{}, fmt.Sprintf(
+			"Dropped unknown parameter %q", parameter.String()), false
+	}
+	if parameter.Value == "" {
+		return Parameter{}, fmt.Sprintf(
+			"Dropped empty parameter %q", parameter.String()), false
+	}
+	if parameter.Prefix == ExactLength {
+		if _, err := parseExactLength(parameter.Value); err != nil {
+			return Parameter{}, fmt.Sprintf(
+				"Dropped malformed parameter %q", parameter.String()), false
+		}
+	}
+	if parameter.Prefix == ExactTopic {
+		return makeExactTopicCompliant(parameter)
+	}
+	space, keep := escapePolicy(parameter.Prefix)
+	escapedValue := escapeValueWith(parameter.Value, space, keep)
+	if escapedValue != parameter.Value {
+		compliantParameter := Parameter{
+			parameter.Prefix, parameter.Index, escapedValue}
+		return compliantParameter, fmt.Sprintf(
+			"Percent-encoded parameter %q", parameter.String()), true
+	}
+	return parameter, "", true
+}
+
+func makeExactTopicCompliant(parameter Parameter) (Parameter, string, bool) {
+	if !strings.HasPrefix(parameter.Value, "urn:") {
+		return Parameter{}, fmt.Sprintf(
+			"Dropped exact topic that is not a URN %q",
+			parameter.String()), false
+	}
+	if !strings.HasPrefix(parameter.Value, bitTorrentInfoHashURNPrefix) {
+		return parameter, "", true
+	}
+	infoHash, err := decodeInfoHash(
+		strings.TrimPrefix(parameter.Value, bitTorrentInfoHashURNPrefix))
+	if err != nil {
+		return Parameter{}, fmt.Sprintf(
+			"Dropped malformed info hash %q", parameter.String()), false
+	}
+	value := bitTorrentInfoHashURNPrefix + hex.EncodeToString(infoHash)
+	if value != parameter.Value {
+		compliantParameter := Parameter{parameter.Prefix, parameter.Index, value}
+		return compliantParameter, fmt.Sprintf(
+			"Normalized info hash %q", parameter.String()), true
+	}
+	return parameter, "", true
+}
+
+func renumberParameters(parameters []Parameter) []string {
+	var changes []string
+	counts := make(map[Prefix]int)
+	for _, parameter := range parameters {
+		counts[parameter.Prefix]++
+	}
+	next := make(map[Prefix]int)
+	for i := range parameters {
+		index := 0
+		if counts[parameters[i].Prefix] > 1 {
+			next[parameters[i].Prefix]++
+			index = next[parameters[i].Prefix]
+		}
+		if parameters[i].Index != index {
+			changes = append(changes, fmt.Sprintf(
+				"Renumbered parameter %q to index %d",
+				parameters[i].String(), index))
+			parameters[i].Index = index
+		}
+	}
+	return changes
+}
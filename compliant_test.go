@@ -0,0 +1,84 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+func TestMakeCompliant(t *testing.T) {
+	messyMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 3, "urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK"},
+			Parameter{"xt", 0, "not a urn"},
+			Parameter{"dn", 2, "My File #1"},
+			Parameter{"zz", 0, "unknown"},
+			Parameter{"tr", 0, ""},
+			Parameter{"tr", 5, "http://tracker1.example/announce"},
+			Parameter{"tr", 7, "http://tracker2.example/announce"},
+			Parameter{"xl", 0, "ten"},
+		},
+	}
+	expectedString := "magnet:?" +
+		"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+		"dn=My+File+%231&" +
+		"tr.1=http://tracker1.example/announce&" +
+		"tr.2=http://tracker2.example/announce"
+	compliantMagnetURI, changes, error := messyMagnetURI.MakeCompliant()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(changes) == 0 {
+		t.Error("No changes were reported.")
+	}
+	compliantString, error := compliantMagnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if compliantString != expectedString {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			expectedString, compliantString)
+	}
+	reparsedMagnetURI, error := Parse(compliantString)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !reparsedMagnetURI.Equal(compliantMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			compliantMagnetURI, reparsedMagnetURI)
+	}
+}
+
+func TestMakeCompliantWithoutExactTopic(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:tooshort"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	expectedErrorMessage := "The Magnet URI has no valid exact topic"
+	compliantMagnetURI, _, error := magnetURI.MakeCompliant()
+	if !compliantMagnetURI.Equal(MagnetURI{}) {
+		t.Errorf("A Magnet URI was returned: %v", compliantMagnetURI)
+	}
+	if error == nil {
+		t.Fatal("No error was returned.")
+	}
+	if error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message: %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const eDonkeyHashURNPrefix = "urn:ed2k:"
+
+// ED2KHash returns the eDonkey2000 hash of the Magnet URI, as written in the
+// first exact topic with a "urn:ed2k:" URN and a valid 32 characters hex hash,
+// and true if it is present.
+func (magnetURI *MagnetURI) ED2KHash() (string, bool) {
+	for _, ed2kHash := range magnetURI.ed2kHashes() {
+		if validateED2KHash(ed2kHash) == nil {
+			return ed2kHash, true
+		}
+	}
+	return "", false
+}
+
+// ED2KHashValid returns the eDonkey2000 hash of the first exact topic with a
+// "urn:ed2k:" URN, or an error if there is no such exact topic or if its hash
+// is not a 32 characters hex string.
+func (magnetURI *MagnetURI) ED2KHashValid() (string, error) {
+	ed2kHashes := magnetURI.ed2kHashes()
+	if len(ed2kHashes) == 0 {
+		return "", errors.New("The Magnet URI has no eDonkey2000 hash")
+	}
+	if err := validateED2KHash(ed2kHashes[0]); err != nil {
+		return "", fmt.Errorf("Wrong eDonkey2000 hash: %q; %w",
+			ed2kHashes[0], err)
+	}
+	return ed2kHashes[0], nil
+}
+
+func (magnetURI *MagnetURI) ed2kHashes() []string {
+	var ed2kHashes []string
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		if strings.HasPrefix(exactTopic.Value, eDonkeyHashURNPrefix) {
+			ed2kHashes = append(ed2kHashes, strings.TrimPrefix(
+				exactTopic.Value, eDonkeyHashURNPrefix))
+		}
+	}
+	return ed2kHashes
+}
+
+// validateED2KHash checks that the hash is the hex form of an MD4 digest.
+func validateED2KHash(ed2kHash string) error {
+	if len(ed2kHash) != hex.EncodedLen(16) {
+		return errors.New(
+			fmt.Sprintf(
+				"Wrong eDonkey2000 hash length: %d; expected 32 hex "+
+					"characters", len(ed2kHash)))
+	}
+	_, err := hex.DecodeString(ed2kHash)
+	return err
+}
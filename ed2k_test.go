@@ -0,0 +1,140 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+func TestED2KHash(t *testing.T) {
+	scenarios := ed2kHashScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		ed2kHash, present := magnetURI.ED2KHash()
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
+		}
+		if ed2kHash != scenario.ExpectedED2KHash {
+			t.Errorf("Error on test %q: expected eDonkey2000 hash %q; got %q",
+				scenario.Name, scenario.ExpectedED2KHash, ed2kHash)
+		}
+	}
+}
+
+type ed2kHashScenario struct {
+	Name             string
+	RawMagnetURI     string
+	ExpectedED2KHash string
+	ExpectedPresent  bool
+}
+
+var ed2kHashScenarios = []ed2kHashScenario{
+	{
+		Name: "eDonkey2000 hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1&dn=name",
+		ExpectedED2KHash: "354B15E68FB8F36D7CD88FF94116CDC1",
+		ExpectedPresent:  true,
+	},
+	{
+		Name: "eDonkey2000 hash after other exact topic",
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"xt.2=urn:ed2k:354b15e68fb8f36d7cd88ff94116cdc1",
+		ExpectedED2KHash: "354b15e68fb8f36d7cd88ff94116cdc1",
+		ExpectedPresent:  true,
+	},
+	{
+		Name:             "eDonkey2000 hash with wrong length",
+		RawMagnetURI:     "magnet:?xt=urn:ed2k:354B15E68FB8",
+		ExpectedED2KHash: "",
+		ExpectedPresent:  false,
+	},
+	{
+		Name:             "eDonkey2000 hash that is not hex",
+		RawMagnetURI:     "magnet:?xt=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDCZ",
+		ExpectedED2KHash: "",
+		ExpectedPresent:  false,
+	},
+	{
+		Name:             "No eDonkey2000 hash",
+		RawMagnetURI:     "magnet:?dn=name",
+		ExpectedED2KHash: "",
+		ExpectedPresent:  false,
+	},
+}
+
+func TestED2KHashValid(t *testing.T) {
+	scenarios := ed2kHashValidScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		ed2kHash, error := magnetURI.ED2KHashValid()
+		if ed2kHash != scenario.ExpectedED2KHash {
+			t.Errorf("Error on test %q: expected eDonkey2000 hash %q; got %q",
+				scenario.Name, scenario.ExpectedED2KHash, ed2kHash)
+		}
+		errorMessage := ""
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedErrorMessage {
+			t.Errorf("Error on test %q: expected error %q; got %q",
+				scenario.Name, scenario.ExpectedErrorMessage, errorMessage)
+		}
+	}
+}
+
+type ed2kHashValidScenario struct {
+	Name                 string
+	RawMagnetURI         string
+	ExpectedED2KHash     string
+	ExpectedErrorMessage string
+}
+
+var ed2kHashValidScenarios = []ed2kHashValidScenario{
+	{
+		Name:             "Valid eDonkey2000 hash",
+		RawMagnetURI:     "magnet:?xt=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1",
+		ExpectedED2KHash: "354B15E68FB8F36D7CD88FF94116CDC1",
+	},
+	{
+		Name:         "eDonkey2000 hash with wrong length",
+		RawMagnetURI: "magnet:?xt=urn:ed2k:354B15E68FB8",
+		ExpectedErrorMessage: "Wrong eDonkey2000 hash: \"354B15E68FB8\"; " +
+			"Wrong eDonkey2000 hash length: 12; expected 32 hex characters",
+	},
+	{
+		Name:         "eDonkey2000 hash that is not hex",
+		RawMagnetURI: "magnet:?xt=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDCZ",
+		ExpectedErrorMessage: "Wrong eDonkey2000 hash: " +
+			"\"354B15E68FB8F36D7CD88FF94116CDCZ\"; " +
+			"encoding/hex: invalid byte: U+005A 'Z'",
+	},
+	{
+		Name:                 "No eDonkey2000 hash",
+		RawMagnetURI:         "magnet:?dn=name",
+		ExpectedErrorMessage: "The Magnet URI has no eDonkey2000 hash",
+	},
+}
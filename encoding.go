@@ -0,0 +1,252 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// MarshalText implements the encoding.TextMarshaler interface.
+// The Magnet URI is encoded as its string form.
+func (magnetURI MagnetURI) MarshalText() ([]byte, error) {
+	s, err := magnetURI.String()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+// The text is parsed as a raw Magnet URI string.
+func (magnetURI *MagnetURI) UnmarshalText(text []byte) error {
+	parsedMagnetURI, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*magnetURI = parsedMagnetURI
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// The Magnet URI is encoded as a JSON string with its string form, or as null
+// if it has no parameters.
+func (magnetURI MagnetURI) MarshalJSON() ([]byte, error) {
+	if magnetURI.IsEmpty() {
+		return []byte("null"), nil
+	}
+	s, err := magnetURI.String()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// The JSON string is parsed as a raw Magnet URI string, and null is decoded
+// as a Magnet URI without parameters.
+func (magnetURI *MagnetURI) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*magnetURI = MagnetURI{}
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return magnetURI.UnmarshalText([]byte(s))
+}
+
+// binaryVersion is the version of the binary encoding of the Magnet URIs.
+const binaryVersion = 1
+
+// binaryPrefixCodes are the built-in prefixes encoded as a single byte by
+// MarshalBinary, with their position in the list plus one as their code.
+// The codes are part of the binary encoding, so new prefixes can only be
+// appended to the list.
+var binaryPrefixCodes = []Prefix{
+	ExactTopic, ExactLength, DisplayName, KeywordTopic,
+	ManifestTopic, AddressTracker, WebSeed, ExactSource,
+	AcceptableSource, Peer, SelectOnly, DHTNode,
+	ExtendedDHTNode,
+}
+
+// binaryPrefixCode returns the code of the prefix in binaryPrefixCodes, or 0
+// if it doesn't have one.
+func binaryPrefixCode(prefix Prefix) byte {
+	for i, codePrefix := range binaryPrefixCodes {
+		if prefix == codePrefix {
+			return byte(i + 1)
+		}
+	}
+	return 0
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// The Magnet URI is encoded as a version byte and the number of parameters,
+// followed by each parameter: its prefix as a byte with the code of a
+// built-in prefix, or as a 0 byte followed by the length-prefixed prefix for
+// other prefixes; its index as a varint; and
+// its length-prefixed value. Lengths are uvarints. A Magnet URI without
+// parameters is encoded too.
+func (magnetURI MagnetURI) MarshalBinary() ([]byte, error) {
+	data := []byte{binaryVersion}
+	data = binary.AppendUvarint(data, uint64(len(magnetURI.Parameters)))
+	for _, parameter := range magnetURI.Parameters {
+		code := binaryPrefixCode(parameter.Prefix)
+		data = append(data, code)
+		if code == 0 {
+			data = appendBinaryString(data, string(parameter.Prefix))
+		}
+		data = binary.AppendVarint(data, int64(parameter.Index))
+		data = appendBinaryString(data, parameter.Value)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+// The data is decoded as encoded by MarshalBinary. The parameters are not
+// checked, so they are decoded exactly as they were encoded.
+func (magnetURI *MagnetURI) UnmarshalBinary(data []byte) error {
+	decoder := binaryDecoder{data: data}
+	if version := decoder.byte(); version != binaryVersion {
+		return errors.New(
+			fmt.Sprintf("Unknown binary Magnet URI version: %d", version))
+	}
+	count := decoder.uvarint()
+	if count > uint64(len(data)) {
+		return errors.New("The binary Magnet URI is truncated")
+	}
+	parameters := make([]Parameter, 0, count)
+	for i := uint64(0); i < count && decoder.err == nil; i++ {
+		var parameter Parameter
+		code := int(decoder.byte())
+		switch {
+		case code == 0:
+			parameter.Prefix = Prefix(decoder.string())
+		case code <= len(binaryPrefixCodes):
+			parameter.Prefix = binaryPrefixCodes[code-1]
+		default:
+			return errors.New(
+				fmt.Sprintf("Wrong binary prefix code: %d", code))
+		}
+		parameter.Index = int(decoder.varint())
+		parameter.Value = decoder.string()
+		parameters = append(parameters, parameter)
+	}
+	if decoder.err != nil {
+		return decoder.err
+	}
+	if len(decoder.data) != 0 {
+		return errors.New(
+			fmt.Sprintf("The binary Magnet URI has %d extra bytes",
+				len(decoder.data)))
+	}
+	if len(parameters) == 0 {
+		parameters = nil
+	}
+	*magnetURI = MagnetURI{Parameters: parameters}
+	return nil
+}
+
+func appendBinaryString(data []byte, s string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(s)))
+	return append(data, s...)
+}
+
+// binaryDecoder reads the data encoded by MarshalBinary. After the first
+// error, the reads return zero values and the error is kept.
+type binaryDecoder struct {
+	data []byte
+	err  error
+}
+
+func (decoder *binaryDecoder) byte() byte {
+	if decoder.err != nil || len(decoder.data) == 0 {
+		decoder.truncated()
+		return 0
+	}
+	b := decoder.data[0]
+	decoder.data = decoder.data[1:]
+	return b
+}
+
+func (decoder *binaryDecoder) uvarint() uint64 {
+	if decoder.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(decoder.data)
+	if n <= 0 {
+		decoder.truncated()
+		return 0
+	}
+	decoder.data = decoder.data[n:]
+	return x
+}
+
+func (decoder *binaryDecoder) varint() int64 {
+	if decoder.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(decoder.data)
+	if n <= 0 {
+		decoder.truncated()
+		return 0
+	}
+	decoder.data = decoder.data[n:]
+	return x
+}
+
+func (decoder *binaryDecoder) string() string {
+	length := decoder.uvarint()
+	if decoder.err != nil || length > uint64(len(decoder.data)) {
+		decoder.truncated()
+		return ""
+	}
+	s := string(decoder.data[:length])
+	decoder.data = decoder.data[length:]
+	return s
+}
+
+func (decoder *binaryDecoder) truncated() {
+	if decoder.err == nil {
+		decoder.err = errors.New("The binary Magnet URI is truncated")
+	}
+}
+
+// LogValue implements the slog.LogValuer interface, so the Magnet URI is
+// logged as a group with its info hash, display name, number of trackers and
+// torrent version. The info hash is the v1 one, or the v2 one if there is no
+// v1 info hash. The info hash and the display name are left out when the
+// Magnet URI does not have them.
+func (magnetURI MagnetURI) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, 4)
+	if infoHash, ok := magnetURI.InfoHash(); ok {
+		attrs = append(attrs, slog.String("info_hash", infoHash))
+	} else if infoHash, ok := magnetURI.InfoHashV2(); ok {
+		attrs = append(attrs, slog.String("info_hash", infoHash))
+	}
+	if displayName, ok := magnetURI.DisplayName(); ok {
+		attrs = append(attrs, slog.String("display_name", displayName))
+	}
+	attrs = append(attrs,
+		slog.Int("tracker_count", len(magnetURI.Trackers())),
+		slog.String("version", magnetURI.TorrentVersion().String()))
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,364 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalText(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		text, error := scenario.URIStruct.MarshalText()
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if string(text) != scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected text: %q; got %q",
+				scenario.Name, scenario.RawMagnetURI, text)
+		}
+	}
+}
+
+func TestMarshalTextWithoutParameters(t *testing.T) {
+	magnetURI := MagnetURI{}
+	text, error := magnetURI.MarshalText()
+	expectedErrorMessage := "The Magnet URI has no parameters."
+	if text != nil {
+		t.Errorf("A text was returned: %q.", text)
+	}
+	if error == nil {
+		t.Fatal("No error was returned.")
+	}
+	if error.Error() != expectedErrorMessage {
+		t.Errorf(
+			"Expected error message: %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		var magnetURI MagnetURI
+		error := magnetURI.UnmarshalText([]byte(scenario.RawMagnetURI))
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+func TestTextInJSON(t *testing.T) {
+	type config struct {
+		Link MagnetURI
+	}
+	rawJSON := `{"Link":"magnet:?kt=martin+luther+king+mp3"}`
+	var decodedConfig config
+	if error := json.Unmarshal([]byte(rawJSON), &decodedConfig); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"kt", 0, "martin+luther+king+mp3"},
+		},
+	}
+	if !decodedConfig.Link.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, decodedConfig.Link)
+	}
+	encodedJSON, error := json.Marshal(decodedConfig)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if string(encodedJSON) != rawJSON {
+		t.Errorf("Expected JSON: %s; got %s", rawJSON, encodedJSON)
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		data, error := json.Marshal(scenario.URIStruct)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		var s string
+		if error := json.Unmarshal(data, &s); error != nil {
+			t.Errorf("Error on test %q: %s is not a JSON string",
+				scenario.Name, data)
+		}
+		if s != scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected JSON string: %q; got %q",
+				scenario.Name, scenario.RawMagnetURI, s)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		data, _ := json.Marshal(scenario.RawMagnetURI)
+		var magnetURI MagnetURI
+		if error := json.Unmarshal(data, &magnetURI); error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+func TestJSONWithoutParameters(t *testing.T) {
+	data, error := json.Marshal(MagnetURI{})
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if string(data) != "null" {
+		t.Errorf("Expected JSON null; got %s", data)
+	}
+	magnetURI := magnetURIConvertionScenarios[0].URIStruct
+	if error := json.Unmarshal(data, &magnetURI); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !magnetURI.Equal(MagnetURI{}) {
+		t.Errorf("Expected an empty Magnet URI; got %v", magnetURI)
+	}
+}
+
+func TestUnmarshalJSONWithErrors(t *testing.T) {
+	for _, data := range []string{`42`, `"not a magnet link"`} {
+		var magnetURI MagnetURI
+		if error := json.Unmarshal([]byte(data), &magnetURI); error == nil {
+			t.Errorf("No error was returned for %s.", data)
+		}
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	magnetURIs := []MagnetURI{
+		MagnetURI{},
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"dn", 0, ""},
+				Parameter{"tr", 2147483647, "http://tracker.example/announce"},
+				Parameter{"x.vendor", 1, "value"},
+				Parameter{"x.dht", 0, "router.example:6881"},
+			},
+		},
+	}
+	for _, scenario := range magnetURIConvertionScenarios {
+		magnetURIs = append(magnetURIs, scenario.URIStruct)
+	}
+	for _, magnetURI := range magnetURIs {
+		data, error := magnetURI.MarshalBinary()
+		if error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		var decodedMagnetURI MagnetURI
+		if error := decodedMagnetURI.UnmarshalBinary(data); error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		if !reflect.DeepEqual(decodedMagnetURI, magnetURI) {
+			t.Errorf("Expected Magnet URI: %#v; got %#v",
+				magnetURI, decodedMagnetURI)
+		}
+	}
+}
+
+func TestBinaryInGob(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[3].URIStruct
+	var buffer bytes.Buffer
+	if error := gob.NewEncoder(&buffer).Encode(magnetURI); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	var decodedMagnetURI MagnetURI
+	error := gob.NewDecoder(&buffer).Decode(&decodedMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !reflect.DeepEqual(decodedMagnetURI, magnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			magnetURI, decodedMagnetURI)
+	}
+}
+
+func TestUnmarshalBinaryWithErrors(t *testing.T) {
+	scenarios := unmarshalBinaryWithErrorsScenarios
+	for _, scenario := range scenarios {
+		var magnetURI MagnetURI
+		error := magnetURI.UnmarshalBinary(scenario.Data)
+		if error == nil {
+			t.Fatalf("Error on test %q: no error was returned",
+				scenario.Name)
+		}
+		if error.Error() != scenario.ExpectedErrorMessage {
+			t.Errorf("Error on test %q: expected error message %q; got %q",
+				scenario.Name, scenario.ExpectedErrorMessage, error.Error())
+		}
+	}
+}
+
+type unmarshalBinaryWithErrorsScenario struct {
+	Name                 string
+	Data                 []byte
+	ExpectedErrorMessage string
+}
+
+var unmarshalBinaryWithErrorsScenarios = []unmarshalBinaryWithErrorsScenario{
+	{
+		Name:                 "Empty data",
+		Data:                 []byte{},
+		ExpectedErrorMessage: "Unknown binary Magnet URI version: 0",
+	},
+	{
+		Name:                 "Unknown version",
+		Data:                 []byte{2, 0},
+		ExpectedErrorMessage: "Unknown binary Magnet URI version: 2",
+	},
+	{
+		Name:                 "Truncated value",
+		Data:                 []byte{1, 1, 1, 0, 5, 'u', 'r', 'n'},
+		ExpectedErrorMessage: "The binary Magnet URI is truncated",
+	},
+	{
+		Name:                 "Wrong prefix code",
+		Data:                 []byte{1, 1, 200, 0, 0},
+		ExpectedErrorMessage: "Wrong binary prefix code: 200",
+	},
+	{
+		Name:                 "Extra bytes",
+		Data:                 []byte{1, 0, 0},
+		ExpectedErrorMessage: "The binary Magnet URI has 1 extra bytes",
+	},
+}
+
+func TestBinaryPrefixCodes(t *testing.T) {
+	// The codes are part of the binary encoding, so they must never change.
+	expectedCodes := map[Prefix]byte{
+		"xt": 1, "xl": 2, "dn": 3, "kt": 4, "mt": 5, "tr": 6, "ws": 7,
+		"xs": 8, "as": 9, "x.pe": 10, "so": 11, "dht": 12, "x.dht": 13,
+	}
+	for prefix, expectedCode := range expectedCodes {
+		if code := binaryPrefixCode(prefix); code != expectedCode {
+			t.Errorf("Expected code %d for prefix %q; got %d",
+				expectedCode, prefix, code)
+		}
+	}
+	for _, prefix := range KnownPrefixes() {
+		if _, ok := expectedCodes[prefix]; !ok && isBuiltInPrefix(prefix) {
+			t.Errorf("The prefix %q has no pinned code.", prefix)
+		}
+	}
+	data := []byte{1, 2, 3, 0, 4, 'n', 'a', 'm', 'e', 0, 8, 'x', '.', 'v',
+		'e', 'n', 'd', 'o', 'r', 2, 1, 'v'}
+	expectedMagnetURI := MagnetURI{[]Parameter{
+		Parameter{"dn", 0, "name"},
+		Parameter{"x.vendor", 1, "v"},
+	}}
+	var magnetURI MagnetURI
+	if error := magnetURI.UnmarshalBinary(data); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !reflect.DeepEqual(magnetURI, expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %#v; got %#v",
+			expectedMagnetURI, magnetURI)
+	}
+	encoded, _ := expectedMagnetURI.MarshalBinary()
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Expected data: %v; got %v", data, encoded)
+	}
+}
+
+func TestLogValue(t *testing.T) {
+	scenarios := logValueScenarios
+	for _, scenario := range scenarios {
+		var buffer bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buffer,
+			&slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+					if len(groups) == 0 && attr.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return attr
+				},
+			}))
+		logger.Info("test", "magnet", scenario.MagnetURI)
+		result := strings.TrimSuffix(buffer.String(), "\n")
+		if result != scenario.ExpectedLog {
+			t.Errorf("Error on test %q: expected log %q; got %q",
+				scenario.Name, scenario.ExpectedLog, result)
+		}
+	}
+}
+
+type logValueScenario struct {
+	Name        string
+	MagnetURI   MagnetURI
+	ExpectedLog string
+}
+
+var logValueScenarios = []logValueScenario{
+	{
+		Name:      "Empty Magnet URI",
+		MagnetURI: MagnetURI{},
+		ExpectedLog: "level=INFO msg=test magnet.tracker_count=0 " +
+			"magnet.version=unknown",
+	},
+	{
+		Name: "BitTorrent v1",
+		MagnetURI: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0,
+					"urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+				Parameter{"dn", 0, "Some+file"},
+				Parameter{"tr", 1, "http://tracker1.example/announce"},
+				Parameter{"tr", 2, "http://tracker2.example/announce"},
+			},
+		},
+		ExpectedLog: "level=INFO msg=test " +
+			"magnet.info_hash=c12fe1c06bba254a9dc9f519b335aa7c1367a88a " +
+			"magnet.display_name=\"Some file\" magnet.tracker_count=2 " +
+			"magnet.version=v1",
+	},
+	{
+		Name: "BitTorrent v2",
+		MagnetURI: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btmh:1220" +
+					"caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e"},
+			},
+		},
+		ExpectedLog: "level=INFO msg=test magnet.info_hash=1220" +
+			"caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e " +
+			"magnet.tracker_count=0 magnet.version=v2",
+	},
+}
@@ -0,0 +1,83 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"fmt"
+)
+
+// SchemaPrefixError is returned when parsing a string that doesn't start with
+// the Magnet URI schema prefix.
+type SchemaPrefixError struct{}
+
+func (err *SchemaPrefixError) Error() string {
+	return fmt.Sprintf(
+		"The string doesn't start with the Magnet URI schema prefix %q",
+		magnetURISchemaPrefix)
+}
+
+// MissingPrefixError is returned when parsing a parameter that has no prefix.
+type MissingPrefixError struct {
+	Parameter string
+}
+
+func (err *MissingPrefixError) Error() string {
+	return fmt.Sprintf("Parameter without prefix: %q", err.Parameter)
+}
+
+// UnknownPrefixError is returned when parsing a parameter with a prefix that
+// is not known.
+type UnknownPrefixError struct {
+	Prefix string
+}
+
+func (err *UnknownPrefixError) Error() string {
+	return fmt.Sprintf("Unknown parameter prefix: %q", err.Prefix)
+}
+
+// PositionError is returned when parsing a parameter fails, with the byte
+// offset of the parameter in the parsed string, so it can be pointed out to
+// the user. Its message is the message of the error of the parameter.
+type PositionError struct {
+	Pos int // The offset of the first byte of the parameter.
+	Err error
+}
+
+func (err *PositionError) Error() string {
+	return err.Err.Error()
+}
+
+// Unwrap returns the error of parsing the parameter.
+func (err *PositionError) Unwrap() error {
+	return err.Err
+}
+
+// ParseError is returned by Parse when the raw Magnet URI can't be parsed,
+// with the raw string, so it can be logged along with the reason.
+type ParseError struct {
+	Raw    string // The string passed to Parse or ParseQuery.
+	Reason string // The message of Err.
+	Err    error
+}
+
+func (err *ParseError) Error() string {
+	return err.Reason
+}
+
+// Unwrap returns the error that made the parsing fail.
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
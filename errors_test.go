@@ -0,0 +1,172 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSchemaPrefixError(t *testing.T) {
+	_, error := Parse("I don't start with the magnet schema prefix.")
+	var schemaPrefixError *SchemaPrefixError
+	if !errors.As(error, &schemaPrefixError) {
+		t.Errorf("Expected a SchemaPrefixError; got %#v", error)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&unknown=value"
+	_, error := Parse(rawMagnetURI)
+	parseError, ok := error.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected a ParseError; got %#v", error)
+	}
+	if parseError.Raw != rawMagnetURI {
+		t.Errorf("Expected raw Magnet URI %q; got %q",
+			rawMagnetURI, parseError.Raw)
+	}
+	expectedReason := "Unknown parameter prefix: \"unknown\""
+	if parseError.Reason != expectedReason {
+		t.Errorf("Expected reason %q; got %q",
+			expectedReason, parseError.Reason)
+	}
+	if parseError.Error() != expectedReason {
+		t.Errorf("Expected error message %q; got %q",
+			expectedReason, parseError.Error())
+	}
+}
+
+func TestMissingPrefixError(t *testing.T) {
+	_, error := Parse("magnet:?parameterwithoutprefix")
+	var missingPrefixError *MissingPrefixError
+	if !errors.As(error, &missingPrefixError) {
+		t.Fatalf("Expected a MissingPrefixError; got %#v", error)
+	}
+	if missingPrefixError.Parameter != "parameterwithoutprefix" {
+		t.Errorf("Expected parameter %q; got %q",
+			"parameterwithoutprefix", missingPrefixError.Parameter)
+	}
+}
+
+func TestUnknownPrefixError(t *testing.T) {
+	_, error := Parse("magnet:?unknown=value")
+	var unknownPrefixError *UnknownPrefixError
+	if !errors.As(error, &unknownPrefixError) {
+		t.Fatalf("Expected an UnknownPrefixError; got %#v", error)
+	}
+	if unknownPrefixError.Prefix != "unknown" {
+		t.Errorf("Expected prefix %q; got %q",
+			"unknown", unknownPrefixError.Prefix)
+	}
+}
+
+func TestIndexErrorChain(t *testing.T) {
+	scenarios := indexErrorChainScenarios
+	for _, scenario := range scenarios {
+		_, error := Parse(scenario.RawMagnetURI)
+		if !errors.Is(error, scenario.ExpectedError) {
+			t.Errorf("Error on test %q: expected %q in the chain of %q",
+				scenario.Name, scenario.ExpectedError, error)
+		}
+		var numError *strconv.NumError
+		if !errors.As(error, &numError) {
+			t.Errorf("Error on test %q: expected a NumError in %q",
+				scenario.Name, error)
+		}
+	}
+}
+
+type indexErrorChainScenario struct {
+	Name          string
+	RawMagnetURI  string
+	ExpectedError error
+}
+
+var indexErrorChainScenarios = []indexErrorChainScenario{
+	{
+		Name:          "Index syntax error",
+		RawMagnetURI:  "magnet:?xt.one=urn:btih:abc",
+		ExpectedError: strconv.ErrSyntax,
+	},
+	{
+		Name:          "Index range error",
+		RawMagnetURI:  "magnet:?xt.99999999999999999999=urn:btih:abc",
+		ExpectedError: strconv.ErrRange,
+	},
+	{
+		Name:          "Exact length syntax error",
+		RawMagnetURI:  "magnet:?xl=ten",
+		ExpectedError: strconv.ErrSyntax,
+	},
+}
+
+func TestPositionError(t *testing.T) {
+	scenarios := positionErrorScenarios
+	for _, scenario := range scenarios {
+		_, error := Parse(scenario.RawMagnetURI)
+		var positionError *PositionError
+		if !errors.As(error, &positionError) {
+			t.Fatalf("Error on test %q: expected a PositionError; got %v",
+				scenario.Name, error)
+		}
+		if positionError.Pos != scenario.ExpectedPos {
+			t.Errorf("Error on test %q: expected position %d; got %d",
+				scenario.Name, scenario.ExpectedPos, positionError.Pos)
+		}
+		if positionError.Error() != positionError.Err.Error() {
+			t.Errorf("Error on test %q: expected error message %q; got %q",
+				scenario.Name, positionError.Err.Error(),
+				positionError.Error())
+		}
+	}
+}
+
+type positionErrorScenario struct {
+	Name         string
+	RawMagnetURI string
+	ExpectedPos  int
+}
+
+var positionErrorScenarios = []positionErrorScenario{
+	{
+		Name:         "First parameter",
+		RawMagnetURI: "magnet:?unknown=value&dn=name",
+		ExpectedPos:  8,
+	},
+	{
+		Name:         "Parameter after others",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&dn=name&xl=ten",
+		ExpectedPos:  32,
+	},
+	{
+		Name:         "Surrounding whitespace",
+		RawMagnetURI: "  magnet:?  dn=name&xt.0=urn:btih:abc ",
+		ExpectedPos:  20,
+	},
+	{
+		Name:         "HTML escaped separators",
+		RawMagnetURI: "magnet:?dn=name&amp;unknown=value",
+		ExpectedPos:  20,
+	},
+	{
+		Name: "After URL value",
+		RawMagnetURI: "magnet:?tr=http://tracker.example/announce?a=1&b=2" +
+			"&xl=ten",
+		ExpectedPos: 51,
+	},
+}
@@ -0,0 +1,194 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NewParameter returns a Parameter with the decoded value encoded to be used
+// in a Magnet URI.
+// Letters, digits and the URL punctuation "-._~:/?@!$'()*," are not escaped,
+// so URNs and tracker URLs are not mangled. The space is written as "+" in
+// display names and keyword topics, and as "%20" in other values, so
+// DecodedValue returns the decoded value back. Every other byte, including
+// "+", "=", ";", "&" and "#", is written as a "%XX" sequence.
+func NewParameter(prefix Prefix, index int, decodedValue string) Parameter {
+	if prefix == DisplayName || prefix == KeywordTopic {
+		return Parameter{prefix, index, encodeValue(decodedValue)}
+	}
+	return Parameter{prefix, index, encodeValueWith(decodedValue, "%20")}
+}
+
+// DecodedValue returns the value of the Parameter with the percent-encoded
+// sequences decoded, and the "+" characters converted to spaces in display
+// names and keyword topics, like DecodedValueWithOptions with
+// DecodePlusAsSpace. The "+" characters in other values, like exact topic
+// URNs, are kept literal.
+func (parameter *Parameter) DecodedValue() (string, error) {
+	return parameter.DecodedValueWithOptions(
+		DecodeOptions{DecodePlusAsSpace: true})
+}
+
+// DecodeOptions control how DecodedValueWithOptions decodes the value of a
+// Parameter.
+type DecodeOptions struct {
+	// DecodePlusAsSpace converts the "+" characters to spaces in the display
+	// name and keyword topic values, the only ones that are free text. The
+	// "+" characters in other values, like exact topic URNs, are kept
+	// literal. By default, every "+" is kept literal; DecodedValue
+	// decodes with this option set.
+	DecodePlusAsSpace bool
+}
+
+// DecodedValueWithOptions returns the value of the Parameter with the
+// percent-encoded sequences decoded, converting the "+" characters as set in
+// the options.
+func (parameter *Parameter) DecodedValueWithOptions(
+	options DecodeOptions) (string, error) {
+	if options.DecodePlusAsSpace && (parameter.Prefix == DisplayName ||
+		parameter.Prefix == KeywordTopic) {
+		return url.QueryUnescape(parameter.Value)
+	}
+	return url.PathUnescape(parameter.Value)
+}
+
+func encodeValue(decodedValue string) string {
+	return encodeValueWith(decodedValue, "+")
+}
+
+// encodeValueWith encodes the decoded value like encodeValue, with the spaces
+// written as space.
+func encodeValueWith(decodedValue string, space string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(decodedValue); i++ {
+		c := decodedValue[i]
+		switch {
+		case c == ' ':
+			encoded.WriteString(space)
+		case strings.IndexByte("+=", c) < 0 && isUnescapedValueByte(c):
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
+
+// escapeValue percent-encodes the characters of a parameter value that can't
+// appear unencoded in a Magnet URI. Letters, digits, the URL punctuation
+// "-._~:/?@!$'()*+,=" and existing percent-encoded sequences are kept as
+// they are, so URNs and URLs are not mangled. The ";" is escaped because it
+// can separate parameters.
+func escapeValue(value string) string {
+	return escapeValueWith(value, "%20", "")
+}
+
+// escapePolicy returns how the spaces are written in the values of the
+// prefix, and the bytes that are kept unescaped in them:
+//   - display names and keyword topics are free text, so their spaces are
+//     written as "+";
+//   - URL values, like address trackers and web seeds, keep their "&" and
+//     ";" characters, that Parse joins back, and their "[" and "]" IPv6 host
+//     delimiters, and their spaces are written as "%20";
+//   - peer addresses and DHT nodes keep their "[" and "]" IPv6 host
+//     delimiters;
+//   - other values, like exact topic URNs, are escaped like escapeValue, so
+//     their ":" delimiters are kept.
+func escapePolicy(prefix Prefix) (space string, keep string) {
+	switch {
+	case prefix == DisplayName || prefix == KeywordTopic:
+		return "+", ""
+	case isURLValuePrefix(prefix):
+		return "%20", "&;[]"
+	case prefix == Peer || prefix == DHTNode ||
+		prefix == ExtendedDHTNode:
+		return "%20", "[]"
+	}
+	return "%20", ""
+}
+
+// escapeValueWith escapes the value like escapeValue, writing the spaces as
+// space and keeping the bytes in keep unescaped too. The value is returned
+// without copying it when nothing has to be escaped.
+func escapeValueWith(value string, space string, keep string) string {
+	i := 0
+	for i < len(value) && !mustEscapeByte(value, i, keep) {
+		i++
+	}
+	if i == len(value) {
+		return value
+	}
+	return string(appendEscapedValue(
+		make([]byte, 0, escapedValueLen(value, space, keep)),
+		value, space, keep))
+}
+
+// appendEscapedValue appends the value escaped like escapeValueWith to dst,
+// without allocating when dst has enough capacity.
+func appendEscapedValue(dst []byte, value string, space string, keep string) []byte {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case !mustEscapeByte(value, i, keep):
+			dst = append(dst, c)
+		case c == ' ':
+			dst = append(dst, space...)
+		default:
+			dst = append(dst, '%', upperHexDigits[c>>4], upperHexDigits[c&0x0f])
+		}
+	}
+	return dst
+}
+
+// escapedValueLen returns the length of the value escaped like
+// escapeValueWith, without escaping it.
+func escapedValueLen(value string, space string, keep string) int {
+	n := len(value)
+	for i := 0; i < len(value); i++ {
+		switch {
+		case !mustEscapeByte(value, i, keep):
+		case value[i] == ' ':
+			n += len(space) - 1
+		default:
+			n += len("%XX") - 1
+		}
+	}
+	return n
+}
+
+const upperHexDigits = "0123456789ABCDEF"
+
+// mustEscapeByte returns true if the byte at i in the value must be escaped,
+// false if it can be written as it is.
+func mustEscapeByte(value string, i int, keep string) bool {
+	c := value[i]
+	if c == '%' {
+		return i+2 >= len(value) || !isHex(value[i+1]) || !isHex(value[i+2])
+	}
+	return !isUnescapedValueByte(c) && strings.IndexByte(keep, c) < 0
+}
+
+func isUnescapedValueByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' ||
+		'0' <= c && c <= '9' || strings.IndexByte("-._~:/?@!$'()*+,=", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
@@ -0,0 +1,241 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+func TestDecodedValue(t *testing.T) {
+	scenarios := decodedValueScenarios
+	for _, scenario := range scenarios {
+		decodedValue, error := scenario.Parameter.DecodedValue()
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if decodedValue != scenario.DecodedValue {
+			t.Errorf("Error on test %q: expected decoded value %q; got %q",
+				scenario.Name, scenario.DecodedValue, decodedValue)
+		}
+	}
+}
+
+func TestDecodedValueWithOptions(t *testing.T) {
+	scenarios := decodedValueWithOptionsScenarios
+	for _, scenario := range scenarios {
+		decodedValue, error := scenario.Parameter.DecodedValueWithOptions(
+			scenario.Options)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if decodedValue != scenario.DecodedValue {
+			t.Errorf("Error on test %q: expected decoded value %q; got %q",
+				scenario.Name, scenario.DecodedValue, decodedValue)
+		}
+	}
+}
+
+type decodedValueWithOptionsScenario struct {
+	Name         string
+	Parameter    Parameter
+	Options      DecodeOptions
+	DecodedValue string
+}
+
+var decodedValueWithOptionsScenarios = []decodedValueWithOptionsScenario{
+	{
+		Name:         "Keyword topic with plus as space",
+		Parameter:    Parameter{"kt", 0, "a+b+c"},
+		Options:      DecodeOptions{DecodePlusAsSpace: true},
+		DecodedValue: "a b c",
+	},
+	{
+		Name:         "Keyword topic with literal plus",
+		Parameter:    Parameter{"kt", 0, "a+b+c"},
+		Options:      DecodeOptions{},
+		DecodedValue: "a+b+c",
+	},
+	{
+		Name:         "Display name with plus as space",
+		Parameter:    Parameter{"dn", 0, "1%2B1+is+2"},
+		Options:      DecodeOptions{DecodePlusAsSpace: true},
+		DecodedValue: "1+1 is 2",
+	},
+	{
+		Name:         "Exact topic with plus as space",
+		Parameter:    Parameter{"xt", 0, "urn:sha1:AB+CD%3D"},
+		Options:      DecodeOptions{DecodePlusAsSpace: true},
+		DecodedValue: "urn:sha1:AB+CD=",
+	},
+}
+
+func TestNewParameter(t *testing.T) {
+	scenarios := decodedValueScenarios
+	for _, scenario := range scenarios {
+		parameter := NewParameter(
+			scenario.Parameter.Prefix, scenario.Parameter.Index,
+			scenario.DecodedValue)
+		if parameter != scenario.Parameter {
+			t.Errorf("Error on test %q: expected parameter %v; got %v",
+				scenario.Name, scenario.Parameter, parameter)
+		}
+	}
+}
+
+type decodedValueScenario struct {
+	Name         string
+	Parameter    Parameter
+	DecodedValue string
+}
+
+var decodedValueScenarios = []decodedValueScenario{
+	{
+		Name: "Spaces as plus signs",
+		Parameter: Parameter{
+			"dn", 0, "Great+Speeches+-+Martin+Luther+King+Jr.+-+" +
+				"I+Have+A+Dream.mp3",
+		},
+		DecodedValue: "Great Speeches - Martin Luther King Jr. - " +
+			"I Have A Dream.mp3",
+	},
+	{
+		Name:         "Reserved characters",
+		Parameter:    Parameter{"dn", 0, "A+%26+B+%3D+C+%23+1%2B1%25"},
+		DecodedValue: "A & B = C # 1+1%",
+	},
+	{
+		Name:         "Exact topic URN",
+		Parameter:    Parameter{"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		DecodedValue: "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+	},
+	{
+		Name:         "Tracker URL",
+		Parameter:    Parameter{"tr", 0, "udp://tracker.example:6969/announce"},
+		DecodedValue: "udp://tracker.example:6969/announce",
+	},
+	{
+		Name:         "Non-ASCII characters",
+		Parameter:    Parameter{"dn", 0, "Canci%C3%B3n"},
+		DecodedValue: "Canción",
+	},
+	{
+		Name:         "Exact topic with literal plus",
+		Parameter:    Parameter{"xt", 0, "urn:sha1:AB%2BCD%20EF"},
+		DecodedValue: "urn:sha1:AB+CD EF",
+	},
+}
+
+func TestDecodedValueKeepsPlusInExactTopics(t *testing.T) {
+	parameter := Parameter{"xt", 0, "urn:sha1:AB+CD"}
+	decodedValue, error := parameter.DecodedValue()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if decodedValue != "urn:sha1:AB+CD" {
+		t.Errorf("Expected decoded value %q; got %q",
+			"urn:sha1:AB+CD", decodedValue)
+	}
+}
+
+func TestParseKeepsEncodedValues(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&dn=My%20File%20Name"
+	magnetURI, error := Parse(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	displayName, error := magnetURI.DisplayNames()[0].DecodedValue()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if displayName != "My File Name" {
+		t.Errorf("Expected display name %q; got %q", "My File Name", displayName)
+	}
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != rawMagnetURI {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			rawMagnetURI, magnetURIString)
+	}
+}
+
+func TestParameterStringEscaping(t *testing.T) {
+	scenarios := parameterStringEscapingScenarios
+	for _, scenario := range scenarios {
+		parameterString := scenario.Parameter.String()
+		if parameterString != scenario.ExpectedString {
+			t.Errorf("Error on test %q: expected parameter %q; got %q",
+				scenario.Name, scenario.ExpectedString, parameterString)
+		}
+	}
+}
+
+type parameterStringEscapingScenario struct {
+	Name           string
+	Parameter      Parameter
+	ExpectedString string
+}
+
+var parameterStringEscapingScenarios = []parameterStringEscapingScenario{
+	{
+		Name:           "Exact topic URN",
+		Parameter:      Parameter{"xt", 0, "urn:sha1:ABC"},
+		ExpectedString: "xt=urn:sha1:ABC",
+	},
+	{
+		Name:           "Exact topic with space",
+		Parameter:      Parameter{"xt", 1, "urn:sha1:A C"},
+		ExpectedString: "xt.1=urn:sha1:A%20C",
+	},
+	{
+		Name:           "Display name with spaces and reserved characters",
+		Parameter:      Parameter{"dn", 0, "A & B #1 50%"},
+		ExpectedString: "dn=A+%26+B+%231+50%25",
+	},
+	{
+		Name:           "Encoded display name",
+		Parameter:      Parameter{"dn", 0, "A+%26+B"},
+		ExpectedString: "dn=A+%26+B",
+	},
+	{
+		Name:           "Display name with semicolon",
+		Parameter:      Parameter{"dn", 0, "A;B"},
+		ExpectedString: "dn=A%3BB",
+	},
+	{
+		Name:           "Keyword topic with spaces",
+		Parameter:      Parameter{"kt", 0, "martin luther king"},
+		ExpectedString: "kt=martin+luther+king",
+	},
+	{
+		Name:           "Tracker URL with query and space",
+		Parameter:      Parameter{"tr", 0, "http://[::1]:80/announce a?x=1&y=2"},
+		ExpectedString: "tr=http://[::1]:80/announce%20a?x=1&y=2",
+	},
+	{
+		Name:           "Web seed with fragment",
+		Parameter:      Parameter{"ws", 0, "http://seed.example/file#part"},
+		ExpectedString: "ws=http://seed.example/file%23part",
+	},
+	{
+		Name:           "IPv6 peer address",
+		Parameter:      Parameter{"x.pe", 0, "[2001:db8::1]:6881"},
+		ExpectedString: "x.pe=[2001:db8::1]:6881",
+	},
+}
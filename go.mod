@@ -0,0 +1,3 @@
+module github.com/come-maiz/magneturi
+
+go 1.23
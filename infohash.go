@@ -0,0 +1,233 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	bitTorrentInfoHashURNPrefix  = "urn:btih:"
+	bitTorrentMultihashURNPrefix = "urn:btmh:"
+)
+
+// InfoHash returns the BitTorrent info hash of the Magnet URI, as written in
+// the first exact topic with a "urn:btih:" URN, and true if it is present.
+// The info hash can be in the 40 characters hex form or in the 32 characters
+// base32 form.
+func (magnetURI *MagnetURI) InfoHash() (string, bool) {
+	for _, infoHash := range magnetURI.infoHashes() {
+		if _, err := decodeInfoHash(infoHash); err == nil {
+			return infoHash, true
+		}
+	}
+	return "", false
+}
+
+// InfoHashHex returns the BitTorrent info hash selected by InfoHash as a
+// lowercase 40 characters hex string, decoding it first if it is in the base32
+// form. If none of the info hashes is valid, it returns the error of the first
+// one.
+func (magnetURI *MagnetURI) InfoHashHex() (string, error) {
+	infoHashes := magnetURI.infoHashes()
+	if len(infoHashes) == 0 {
+		return "", errors.New("The Magnet URI has no BitTorrent info hash")
+	}
+	var firstErr error
+	for _, infoHash := range infoHashes {
+		decodedInfoHash, err := decodeInfoHash(infoHash)
+		if err == nil {
+			return hex.EncodeToString(decodedInfoHash), nil
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("Wrong info hash: %q; %w", infoHash, err)
+		}
+	}
+	return "", firstErr
+}
+
+// InfoHashValid returns true if the Magnet URI has BitTorrent info hashes and
+// all of them are 40 hex or 32 base32 characters long, false if not. Use
+// ValidateInfoHashes to get the wrong info hashes.
+func (magnetURI *MagnetURI) InfoHashValid() bool {
+	return len(magnetURI.infoHashes()) != 0 &&
+		magnetURI.ValidateInfoHashes() == nil
+}
+
+func (magnetURI *MagnetURI) infoHashes() []string {
+	var infoHashes []string
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		if strings.HasPrefix(exactTopic.Value, bitTorrentInfoHashURNPrefix) {
+			infoHashes = append(infoHashes, strings.TrimPrefix(
+				exactTopic.Value, bitTorrentInfoHashURNPrefix))
+		}
+	}
+	return infoHashes
+}
+
+// InfoHashV2 returns the BitTorrent v2 info hash of the Magnet URI, as the hex
+// multihash written in the first exact topic with a "urn:btmh:" URN, and true
+// if it is present.
+// A hybrid Magnet URI has both a BitTorrent v1 info hash, returned by
+// InfoHash, and a v2 info hash.
+func (magnetURI *MagnetURI) InfoHashV2() (string, bool) {
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		if !strings.HasPrefix(exactTopic.Value, bitTorrentMultihashURNPrefix) {
+			continue
+		}
+		multihash := strings.TrimPrefix(
+			exactTopic.Value, bitTorrentMultihashURNPrefix)
+		if _, err := decodeMultihash(multihash); err == nil {
+			return multihash, true
+		}
+	}
+	return "", false
+}
+
+// TorrentVersion is the version of the BitTorrent protocol of a Magnet URI.
+type TorrentVersion int
+
+const (
+	// TorrentVersionUnknown is the version of Magnet URIs without BitTorrent
+	// info hashes.
+	TorrentVersionUnknown TorrentVersion = iota
+	// TorrentVersionV1 is the version of Magnet URIs with only "urn:btih:"
+	// info hashes.
+	TorrentVersionV1
+	// TorrentVersionV2 is the version of Magnet URIs with only "urn:btmh:"
+	// info hashes.
+	TorrentVersionV2
+	// TorrentVersionHybrid is the version of Magnet URIs with both
+	// "urn:btih:" and "urn:btmh:" info hashes.
+	TorrentVersionHybrid
+)
+
+var torrentVersionNames = []string{"unknown", "v1", "v2", "hybrid"}
+
+func (version TorrentVersion) String() string {
+	if version < 0 || int(version) >= len(torrentVersionNames) {
+		return fmt.Sprintf("TorrentVersion(%d)", int(version))
+	}
+	return torrentVersionNames[version]
+}
+
+// TorrentVersion returns the version of the BitTorrent protocol of the Magnet
+// URI, from the URN schemes of its exact topics.
+func (magnetURI *MagnetURI) TorrentVersion() TorrentVersion {
+	v1 := false
+	v2 := false
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		v1 = v1 ||
+			strings.HasPrefix(exactTopic.Value, bitTorrentInfoHashURNPrefix)
+		v2 = v2 ||
+			strings.HasPrefix(exactTopic.Value, bitTorrentMultihashURNPrefix)
+	}
+	switch {
+	case v1 && v2:
+		return TorrentVersionHybrid
+	case v1:
+		return TorrentVersionV1
+	case v2:
+		return TorrentVersionV2
+	}
+	return TorrentVersionUnknown
+}
+
+// MatchesInfoHash returns true if one of the BitTorrent info hashes of the
+// Magnet URI is equal to hash, false if not.
+// Info hashes in both the hex and the base32 forms are compared.
+func (magnetURI *MagnetURI) MatchesInfoHash(hash [20]byte) bool {
+	for _, infoHash := range magnetURI.infoHashes() {
+		decodedInfoHash, err := decodeInfoHash(infoHash)
+		if err == nil && bytes.Equal(decodedInfoHash, hash[:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// SameTorrent returns true if both Magnet URIs have a BitTorrent info hash in
+// common, false if not. The v1 info hashes are compared decoded, so the hex
+// and the base32 forms of the same info hash are equal, and the v2 info
+// hashes are compared without regard to case.
+func (magnetURI MagnetURI) SameTorrent(other MagnetURI) bool {
+	torrentHashes := magnetURI.torrentHashes()
+	for torrentHash := range other.torrentHashes() {
+		if torrentHashes[torrentHash] {
+			return true
+		}
+	}
+	return false
+}
+
+// torrentHashes returns the set of valid BitTorrent v1 and v2 info hashes of
+// the Magnet URI, as URNs with lowercase hex hashes.
+func (magnetURI *MagnetURI) torrentHashes() map[string]bool {
+	torrentHashes := make(map[string]bool)
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		if strings.HasPrefix(exactTopic.Value, bitTorrentInfoHashURNPrefix) {
+			infoHash, err := decodeInfoHash(strings.TrimPrefix(
+				exactTopic.Value, bitTorrentInfoHashURNPrefix))
+			if err == nil {
+				torrentHashes[bitTorrentInfoHashURNPrefix+
+					hex.EncodeToString(infoHash)] = true
+			}
+		}
+		if strings.HasPrefix(exactTopic.Value, bitTorrentMultihashURNPrefix) {
+			multihash, err := decodeMultihash(strings.TrimPrefix(
+				exactTopic.Value, bitTorrentMultihashURNPrefix))
+			if err == nil {
+				torrentHashes[bitTorrentMultihashURNPrefix+
+					hex.EncodeToString(multihash)] = true
+			}
+		}
+	}
+	return torrentHashes
+}
+
+func decodeInfoHash(infoHash string) ([]byte, error) {
+	switch len(infoHash) {
+	case hex.EncodedLen(20):
+		return hex.DecodeString(infoHash)
+	case base32.StdEncoding.EncodedLen(20):
+		return base32.StdEncoding.DecodeString(strings.ToUpper(infoHash))
+	}
+	return nil, errors.New(
+		fmt.Sprintf(
+			"Wrong info hash length: %d; expected 40 hex or 32 base32 "+
+				"characters", len(infoHash)))
+}
+
+// decodeMultihash decodes a hex multihash, checking that the digest length
+// in its header matches the length of the digest.
+func decodeMultihash(multihash string) ([]byte, error) {
+	decodedMultihash, err := hex.DecodeString(multihash)
+	if err != nil {
+		return nil, err
+	}
+	if len(decodedMultihash) < 2 ||
+		int(decodedMultihash[1]) != len(decodedMultihash)-2 {
+		return nil, errors.New(
+			fmt.Sprintf(
+				"Wrong multihash length: %d bytes", len(decodedMultihash)))
+	}
+	return decodedMultihash, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+var testInfoHash = [20]byte{
+	0xc1, 0x2f, 0xe1, 0xc0, 0x6b, 0xba, 0x25, 0x4a, 0x9d, 0xc9,
+	0xf5, 0x19, 0xb3, 0x35, 0xaa, 0x7c, 0x13, 0x67, 0xa8, 0x8a,
+}
+
+func TestInfoHash(t *testing.T) {
+	scenarios := infoHashScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		infoHash, present := magnetURI.InfoHash()
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
+		}
+		if infoHash != scenario.ExpectedInfoHash {
+			t.Errorf("Error on test %q: expected info hash %q; got %q",
+				scenario.Name, scenario.ExpectedInfoHash, infoHash)
+		}
+	}
+}
+
+type infoHashScenario struct {
+	Name             string
+	RawMagnetURI     string
+	ExpectedInfoHash string
+	ExpectedPresent  bool
+}
+
+var infoHashScenarios = []infoHashScenario{
+	{
+		Name: "Hex info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=name",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedPresent:  true,
+	},
+	{
+		Name:             "Base32 info hash",
+		RawMagnetURI:     "magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		ExpectedInfoHash: "YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		ExpectedPresent:  true,
+	},
+	{
+		Name: "Info hash after other exact topic",
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"xt.2=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedPresent:  true,
+	},
+	{
+		Name:             "Info hash with wrong length",
+		RawMagnetURI:     "magnet:?xt=urn:btih:c12fe1c06bba",
+		ExpectedInfoHash: "",
+		ExpectedPresent:  false,
+	},
+	{
+		Name:             "No info hash",
+		RawMagnetURI:     "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		ExpectedInfoHash: "",
+		ExpectedPresent:  false,
+	},
+}
+
+func TestInfoHashHex(t *testing.T) {
+	scenarios := infoHashHexScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		infoHash, error := magnetURI.InfoHashHex()
+		if infoHash != scenario.ExpectedInfoHash {
+			t.Errorf("Error on test %q: expected info hash %q; got %q",
+				scenario.Name, scenario.ExpectedInfoHash, infoHash)
+		}
+		errorMessage := ""
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf("Error on test %q: expected error %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+	}
+}
+
+type infoHashHexScenario struct {
+	Name             string
+	RawMagnetURI     string
+	ExpectedInfoHash string
+	ExpectedError    string
+}
+
+var infoHashHexScenarios = []infoHashHexScenario{
+	{
+		Name: "Uppercase hex info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	},
+	{
+		Name:             "Uppercase base32 info hash",
+		RawMagnetURI:     "magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	},
+	{
+		Name:             "Lowercase base32 info hash",
+		RawMagnetURI:     "magnet:?xt=urn:btih:yex6dqdlxisuvhoj6um3gnnkpqjwpkek",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	},
+	{
+		Name:         "Info hash with wrong length",
+		RawMagnetURI: "magnet:?xt=urn:btih:c12fe1c06bba",
+		ExpectedError: "Wrong info hash: \"c12fe1c06bba\"; " +
+			"Wrong info hash length: 12; " +
+			"expected 40 hex or 32 base32 characters",
+	},
+	{
+		Name: "Wrong info hash before a valid one",
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:btih:c12fe1c06bba&" +
+			"xt.2=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	},
+	{
+		Name: "Info hash with wrong hex characters",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:z12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedError: "Wrong info hash: " +
+			"\"z12fe1c06bba254a9dc9f519b335aa7c1367a88a\"; " +
+			"encoding/hex: invalid byte: U+007A 'z'",
+	},
+	{
+		Name:          "No info hash",
+		RawMagnetURI:  "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		ExpectedError: "The Magnet URI has no BitTorrent info hash",
+	},
+}
+
+func TestInfoHashV2(t *testing.T) {
+	scenarios := infoHashV2Scenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		infoHash, present := magnetURI.InfoHash()
+		if infoHash != scenario.ExpectedInfoHash {
+			t.Errorf("Error on test %q: expected info hash %q; got %q",
+				scenario.Name, scenario.ExpectedInfoHash, infoHash)
+		}
+		if present != (scenario.ExpectedInfoHash != "") {
+			t.Errorf("Error on test %q: info hash present %t",
+				scenario.Name, present)
+		}
+		infoHashV2, present := magnetURI.InfoHashV2()
+		if infoHashV2 != scenario.ExpectedInfoHashV2 {
+			t.Errorf("Error on test %q: expected v2 info hash %q; got %q",
+				scenario.Name, scenario.ExpectedInfoHashV2, infoHashV2)
+		}
+		if present != (scenario.ExpectedInfoHashV2 != "") {
+			t.Errorf("Error on test %q: v2 info hash present %t",
+				scenario.Name, present)
+		}
+	}
+}
+
+type infoHashV2Scenario struct {
+	Name               string
+	RawMagnetURI       string
+	ExpectedInfoHash   string
+	ExpectedInfoHashV2 string
+}
+
+var infoHashV2Scenarios = []infoHashV2Scenario{
+	{
+		Name: "BitTorrent v2",
+		RawMagnetURI: "magnet:?xt=urn:btmh:" +
+			"1220caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e",
+		ExpectedInfoHashV2: "1220caf1e1c30e81cb361b9ee167c4aa" +
+			"64228a7fa4fa9f6105232b28ad099f3a302e",
+	},
+	{
+		Name: "Hybrid",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac&" +
+			"xt=urn:btmh:" +
+			"1220d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+		ExpectedInfoHash: "631a31dd0a46257d5078c0dee4e66e26f73e42ac",
+		ExpectedInfoHashV2: "1220d8dd32ac93357c368556af3ac1d9" +
+			"5c9d76bd0dff6fa9833ecdac3d53134efabb",
+	},
+	{
+		Name: "Truncated multihash",
+		RawMagnetURI: "magnet:?xt=urn:btmh:" +
+			"1220caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f61",
+	},
+	{
+		Name: "BitTorrent v1",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedInfoHash: "c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+	},
+}
+
+func TestMatchesInfoHash(t *testing.T) {
+	scenarios := matchesInfoHashScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		result := magnetURI.MatchesInfoHash(scenario.InfoHash)
+		if result != scenario.ExpectedResult {
+			t.Errorf("Error on test %q: matching %x returns %t.",
+				scenario.Name, scenario.InfoHash, result)
+		}
+	}
+}
+
+type matchesInfoHashScenario struct {
+	Name           string
+	RawMagnetURI   string
+	InfoHash       [20]byte
+	ExpectedResult bool
+}
+
+var matchesInfoHashScenarios = []matchesInfoHashScenario{
+	{
+		Name: "Matching hex info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		InfoHash:       testInfoHash,
+		ExpectedResult: true,
+	},
+	{
+		Name: "Matching uppercase hex info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A",
+		InfoHash:       testInfoHash,
+		ExpectedResult: true,
+	},
+	{
+		Name:           "Matching base32 info hash",
+		RawMagnetURI:   "magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		InfoHash:       testInfoHash,
+		ExpectedResult: true,
+	},
+	{
+		Name: "Non-matching info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:0000000000000000000000000000000000000000",
+		InfoHash:       testInfoHash,
+		ExpectedResult: false,
+	},
+	{
+		Name:           "No info hash",
+		RawMagnetURI:   "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		InfoHash:       testInfoHash,
+		ExpectedResult: false,
+	},
+}
+
+func TestSameTorrent(t *testing.T) {
+	scenarios := sameTorrentScenarios
+	for _, scenario := range scenarios {
+		first, error := Parse(scenario.FirstRawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		second, error := Parse(scenario.SecondRawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if first.SameTorrent(second) != scenario.ExpectedSame {
+			t.Errorf("Error on test %q: expected same torrent %t",
+				scenario.Name, scenario.ExpectedSame)
+		}
+		if second.SameTorrent(first) != scenario.ExpectedSame {
+			t.Errorf("Error on test %q: expected reversed same torrent %t",
+				scenario.Name, scenario.ExpectedSame)
+		}
+	}
+}
+
+type sameTorrentScenario struct {
+	Name               string
+	FirstRawMagnetURI  string
+	SecondRawMagnetURI string
+	ExpectedSame       bool
+}
+
+var sameTorrentScenarios = []sameTorrentScenario{
+	{
+		Name: "Hex and base32 info hashes",
+		FirstRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=first",
+		SecondRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK&dn=second",
+		ExpectedSame: true,
+	},
+	{
+		Name: "Hex info hashes with different case",
+		FirstRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		SecondRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A",
+		ExpectedSame: true,
+	},
+	{
+		Name: "Hybrid and v2",
+		FirstRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac&" +
+			"xt=urn:btmh:" +
+			"1220d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+		SecondRawMagnetURI: "magnet:?xt=urn:btmh:" +
+			"1220D8DD32AC93357C368556AF3AC1D95C9D76BD0DFF6FA9833ECDAC3D53134EFABB",
+		ExpectedSame: true,
+	},
+	{
+		Name: "Different info hashes",
+		FirstRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		SecondRawMagnetURI: "magnet:?" +
+			"xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac",
+		ExpectedSame: false,
+	},
+	{
+		Name:               "No info hashes",
+		FirstRawMagnetURI:  "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		SecondRawMagnetURI: "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		ExpectedSame:       false,
+	},
+}
+
+func TestTorrentVersion(t *testing.T) {
+	scenarios := torrentVersionScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		version := magnetURI.TorrentVersion()
+		if version != scenario.ExpectedVersion {
+			t.Errorf("Error on test %q: expected version %v; got %v",
+				scenario.Name, scenario.ExpectedVersion, version)
+		}
+	}
+}
+
+type torrentVersionScenario struct {
+	Name            string
+	RawMagnetURI    string
+	ExpectedVersion TorrentVersion
+}
+
+var torrentVersionScenarios = []torrentVersionScenario{
+	{
+		Name: "BitTorrent v1",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedVersion: TorrentVersionV1,
+	},
+	{
+		Name: "BitTorrent v2",
+		RawMagnetURI: "magnet:?xt=urn:btmh:" +
+			"1220caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e",
+		ExpectedVersion: TorrentVersionV2,
+	},
+	{
+		Name: "Hybrid",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:631a31dd0a46257d5078c0dee4e66e26f73e42ac&" +
+			"xt=urn:btmh:" +
+			"1220d8dd32ac93357c368556af3ac1d95c9d76bd0dff6fa9833ecdac3d53134efabb",
+		ExpectedVersion: TorrentVersionHybrid,
+	},
+	{
+		Name:            "Not BitTorrent",
+		RawMagnetURI:    "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		ExpectedVersion: TorrentVersionUnknown,
+	},
+}
+
+func TestTorrentVersionString(t *testing.T) {
+	if s := TorrentVersionHybrid.String(); s != "hybrid" {
+		t.Errorf("Expected %q; got %q", "hybrid", s)
+	}
+	if s := TorrentVersion(7).String(); s != "TorrentVersion(7)" {
+		t.Errorf("Expected %q; got %q", "TorrentVersion(7)", s)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxLineLength is the length of the longest line read by ParseReader.
+const maxLineLength = 1024 * 1024
+
+// LineError is returned by ParseReader when a line can't be parsed.
+type LineError struct {
+	Line int // The first line is 1.
+	Err  error
+}
+
+func (err *LineError) Error() string {
+	return fmt.Sprintf("Line %d: %s", err.Line, err.Err.Error())
+}
+
+// Unwrap returns the error of parsing the line.
+func (err *LineError) Unwrap() error {
+	return err.Err
+}
+
+// ParseReader parses the raw Magnet URIs read from r, one per line.
+// Blank lines and lines starting with "#" are skipped. The lines that can't
+// be parsed are skipped too, and their errors are returned as *LineError.
+func ParseReader(r io.Reader) ([]MagnetURI, []error) {
+	links, errs, _ := parseReader(context.Background(), r)
+	return links, errs
+}
+
+// contextCheckLines is the number of lines read by ParseReaderContext between
+// checks of its context.
+const contextCheckLines = 100
+
+// ParseReaderContext parses the raw Magnet URIs read from r, one per line,
+// like ParseReader. The context is checked every few lines; if it is done,
+// the Magnet URIs parsed so far are returned with the error of the context.
+// Otherwise, the errors of the lines that can't be parsed are returned joined
+// in a single error, which is nil if all the lines were parsed.
+func ParseReaderContext(
+	ctx context.Context, r io.Reader) ([]MagnetURI, error) {
+	links, errs, err := parseReader(ctx, r)
+	if err != nil {
+		return links, err
+	}
+	return links, errors.Join(errs...)
+}
+
+func parseReader(
+	ctx context.Context, r io.Reader) ([]MagnetURI, []error, error) {
+	var links []MagnetURI
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	for line := 1; ; line++ {
+		if line%contextCheckLines == 1 {
+			if err := ctx.Err(); err != nil {
+				return links, errs, err
+			}
+		}
+		if !scanner.Scan() {
+			break
+		}
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		link, err := Parse(text)
+		if err != nil {
+			errs = append(errs, &LineError{line, err})
+			continue
+		}
+		links = append(links, link)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return links, errs, nil
+}
+
+// ParseMulti parses each of the raw Magnet URI strings. The returned Magnet
+// URIs and errors are in the same order as the raw strings, with nil errors
+// for the strings that were parsed and empty Magnet URIs for those that
+// failed.
+func ParseMulti(raws []string) ([]MagnetURI, []error) {
+	links := make([]MagnetURI, len(raws))
+	errs := make([]error, len(raws))
+	for i, raw := range raws {
+		links[i], errs[i] = Parse(raw)
+	}
+	return links, errs
+}
+
+// magnetURIPattern matches the Magnet URIs in a text, until a whitespace, a
+// quote or an angle bracket.
+var magnetURIPattern = regexp.MustCompile(`magnet:\?[^\s"'<>]+`)
+
+// FindAll returns the Magnet URIs found in the text, like an HTML page or a
+// chat log, in the order they appear. The "&amp;" HTML entities are decoded
+// by Parse, and the Magnet URIs that can't be parsed are skipped.
+func FindAll(text string) []MagnetURI {
+	var links []MagnetURI
+	for _, raw := range magnetURIPattern.FindAllString(text, -1) {
+		link, err := Parse(raw)
+		if err != nil {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// ResolveManifest fetches the manifests of the manifest topics of the Magnet
+// URI with the fetch function, and returns the Magnet URIs found in them, in
+// order. The manifests are read like FindAll, so they can be RSS feeds, HTML
+// pages or lists of Magnet URIs. The package does no network access; it is
+// all done by fetch.
+// The manifest topics that are not valid URLs or that can't be fetched are
+// skipped, and their errors are joined in the returned error.
+func (magnetURI *MagnetURI) ResolveManifest(
+	fetch func(*url.URL) ([]byte, error)) ([]MagnetURI, error) {
+	urls, err := magnetURI.ManifestTopicURLs()
+	errs := []error{err}
+	var links []MagnetURI
+	for _, u := range urls {
+		if u == nil {
+			continue
+		}
+		body, err := fetch(u)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"Can't fetch the manifest %q; %w", u.String(), err))
+			continue
+		}
+		links = append(links, FindAll(string(body))...)
+	}
+	return links, errors.Join(errs...)
+}
+
+// WriteList writes the Magnet URIs to w, one per line.
+// If canonical is true, the parameters of each Magnet URI are written in the
+// canonical order. Lines that are exact duplicates of a previous line are
+// skipped.
+func WriteList(w io.Writer, links []MagnetURI, canonical bool) error {
+	written := make(map[string]bool, len(links))
+	for _, link := range links {
+		var line string
+		var err error
+		if canonical {
+			line, err = link.Canonical()
+		} else {
+			line, err = link.String()
+		}
+		if err != nil {
+			return err
+		}
+		if written[line] {
+			continue
+		}
+		written[line] = true
+		if _, err = io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
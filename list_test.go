@@ -0,0 +1,325 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteList(t *testing.T) {
+	scenarios := writeListScenarios
+	for _, scenario := range scenarios {
+		links := make([]MagnetURI, 0, len(scenario.RawMagnetURIs))
+		for _, rawMagnetURI := range scenario.RawMagnetURIs {
+			link, error := Parse(rawMagnetURI)
+			if error != nil {
+				t.Fatalf("There was an error on test %q: %q",
+					scenario.Name, error.Error())
+			}
+			links = append(links, link)
+		}
+		var buffer bytes.Buffer
+		error := WriteList(&buffer, links, scenario.Canonical)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+		if len(lines) != len(scenario.ExpectedLines) {
+			t.Fatalf("Error on test %q: expected lines: %q; got %q",
+				scenario.Name, scenario.ExpectedLines, lines)
+		}
+		for i, line := range lines {
+			if line != scenario.ExpectedLines[i] {
+				t.Errorf("Error on test %q: expected line: %q; got %q",
+					scenario.Name, scenario.ExpectedLines[i], line)
+			}
+			if _, error := Parse(line); error != nil {
+				t.Errorf("Error on test %q: line %q can't be read back: %q",
+					scenario.Name, line, error.Error())
+			}
+		}
+	}
+}
+
+type writeListScenario struct {
+	Name          string
+	RawMagnetURIs []string
+	Canonical     bool
+	ExpectedLines []string
+}
+
+var writeListScenarios = []writeListScenario{
+	{
+		Name: "Exact duplicates",
+		RawMagnetURIs: []string{
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?kt=martin+luther+king+mp3",
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?dn=name&xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		},
+		Canonical: false,
+		ExpectedLines: []string{
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?kt=martin+luther+king+mp3",
+			"magnet:?dn=name&xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		},
+	},
+	{
+		Name: "Canonical duplicates",
+		RawMagnetURIs: []string{
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?kt=martin+luther+king+mp3",
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?dn=name&xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		},
+		Canonical: true,
+		ExpectedLines: []string{
+			"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name",
+			"magnet:?kt=martin+luther+king+mp3",
+		},
+	},
+}
+
+func TestParseReader(t *testing.T) {
+	input := "# Speeches\n" +
+		"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name\n" +
+		"\n" +
+		"not a magnet link\n" +
+		"  magnet:?kt=martin+luther+king+mp3  \n" +
+		"magnet:?unknown=value"
+	expectedLinks := []MagnetURI{
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"kt", 0, "martin+luther+king+mp3"},
+			},
+		},
+	}
+	expectedErrors := []string{
+		"Line 4: The string doesn't start with the Magnet URI schema " +
+			"prefix \"magnet:?\"",
+		"Line 6: Unknown parameter prefix: \"unknown\"",
+	}
+	links, errors := ParseReader(strings.NewReader(input))
+	if len(links) != len(expectedLinks) {
+		t.Fatalf("Expected links: %v; got %v", expectedLinks, links)
+	}
+	for i, link := range links {
+		if !link.Equal(expectedLinks[i]) {
+			t.Errorf("Expected link: %v; got %v", expectedLinks[i], link)
+		}
+	}
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("Expected errors: %q; got %q", expectedErrors, errors)
+	}
+	for i, error := range errors {
+		if error.Error() != expectedErrors[i] {
+			t.Errorf("Expected error message: %q; got %q",
+				expectedErrors[i], error.Error())
+		}
+	}
+}
+
+func TestParseReaderContext(t *testing.T) {
+	input := "magnet:?kt=martin+luther+king+mp3\n" +
+		"not a magnet link\n" +
+		"magnet:?dn=name"
+	links, error := ParseReaderContext(
+		context.Background(), strings.NewReader(input))
+	if len(links) != 2 {
+		t.Errorf("Expected 2 links; got %v", links)
+	}
+	expectedErrorMessage := "Line 2: The string doesn't start with the " +
+		"Magnet URI schema prefix \"magnet:?\""
+	if error == nil || error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message: %q; got %v",
+			expectedErrorMessage, error)
+	}
+}
+
+// cancellingReader returns one line per read, and cancels its context after
+// returning cancelAfter lines.
+type cancellingReader struct {
+	line        string
+	lines       int
+	cancelAfter int
+	cancel      context.CancelFunc
+}
+
+func (r *cancellingReader) Read(p []byte) (int, error) {
+	r.lines++
+	if r.lines == r.cancelAfter {
+		r.cancel()
+	}
+	return copy(p, r.line), nil
+}
+
+func TestParseReaderContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &cancellingReader{
+		line:        "magnet:?dn=name\n",
+		cancelAfter: 50,
+		cancel:      cancel,
+	}
+	links, error := ParseReaderContext(ctx, r)
+	if !errors.Is(error, context.Canceled) {
+		t.Errorf("Expected error %q; got %v", context.Canceled, error)
+	}
+	if len(links) != contextCheckLines {
+		t.Errorf("Expected %d links; got %d", contextCheckLines, len(links))
+	}
+}
+
+func TestParseReaderContextCancelledBeforeStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	links, error := ParseReaderContext(
+		ctx, strings.NewReader("magnet:?dn=name"))
+	if !errors.Is(error, context.Canceled) {
+		t.Errorf("Expected error %q; got %v", context.Canceled, error)
+	}
+	if len(links) != 0 {
+		t.Errorf("Expected no links; got %v", links)
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	text := "<p>Download <a href=\"magnet:?xt=urn:btih:" +
+		"c12fe1c06bba254a9dc9f519b335aa7c1367a88a&amp;dn=name\">" +
+		"the speech</a> or magnet:?kt=martin+luther+king+mp3\n" +
+		"but not magnet:?unknown=value or 'magnet:?dn=other'</p>"
+	expectedLinks := []MagnetURI{
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0,
+					"urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"kt", 0, "martin+luther+king+mp3"},
+			},
+		},
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"dn", 0, "other"},
+			},
+		},
+	}
+	links := FindAll(text)
+	if len(links) != len(expectedLinks) {
+		t.Fatalf("Expected links: %v; got %v", expectedLinks, links)
+	}
+	for i, link := range links {
+		if !link.Equal(expectedLinks[i]) {
+			t.Errorf("Expected link: %v; got %v", expectedLinks[i], link)
+		}
+	}
+}
+
+func TestResolveManifest(t *testing.T) {
+	var fetched []string
+	fetch := func(u *url.URL) ([]byte, error) {
+		fetched = append(fetched, u.String())
+		if u.Path == "/missing.rss" {
+			return nil, errors.New("Not found")
+		}
+		return []byte("<rss><item><link>magnet:?xt=urn:btih:" +
+			"c12fe1c06bba254a9dc9f519b335aa7c1367a88a&amp;dn=name</link>" +
+			"</item><item><link>magnet:?kt=martin</link></item></rss>"), nil
+	}
+	magnetURI, error := Parse("magnet:?" +
+		"mt.1=http://weblog.foo/all-my-favorites.rss&" +
+		"mt.2=not+a+url&" +
+		"mt.3=http://weblog.foo/missing.rss")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	links, error := magnetURI.ResolveManifest(fetch)
+	expectedFetched := []string{
+		"http://weblog.foo/all-my-favorites.rss",
+		"http://weblog.foo/missing.rss",
+	}
+	if !reflect.DeepEqual(fetched, expectedFetched) {
+		t.Errorf("Expected fetched URLs: %q; got %q", expectedFetched, fetched)
+	}
+	expectedLinks := []MagnetURI{
+		MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0,
+					"urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		MagnetURI{
+			Parameters: []Parameter{Parameter{"kt", 0, "martin"}},
+		},
+	}
+	if !reflect.DeepEqual(links, expectedLinks) {
+		t.Errorf("Expected links: %v; got %v", expectedLinks, links)
+	}
+	expectedErrorMessage := "Wrong URL at index 1: \"not+a+url\"; " +
+		"The URL is not absolute\n" +
+		"Can't fetch the manifest \"http://weblog.foo/missing.rss\"; " +
+		"Not found"
+	if error == nil || error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message %q; got %v",
+			expectedErrorMessage, error)
+	}
+}
+
+func TestParseMulti(t *testing.T) {
+	raws := []string{
+		"magnet:?kt=martin+luther+king+mp3",
+		"not a magnet link",
+		"magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+	}
+	links, errors := ParseMulti(raws)
+	if len(links) != len(raws) || len(errors) != len(raws) {
+		t.Fatalf("Expected %d results; got %d links and %d errors",
+			len(raws), len(links), len(errors))
+	}
+	for _, i := range []int{0, 2} {
+		if errors[i] != nil {
+			t.Errorf("There was an error on input %d: %q",
+				i, errors[i].Error())
+		}
+		expectedLink, _ := Parse(raws[i])
+		if !links[i].Equal(expectedLink) {
+			t.Errorf("Expected link %d: %v; got %v",
+				i, expectedLink, links[i])
+		}
+	}
+	if errors[1] == nil {
+		t.Error("No error was returned for input 1.")
+	}
+	if !links[1].Equal(MagnetURI{}) {
+		t.Errorf("A link was returned for input 1: %v", links[1])
+	}
+}
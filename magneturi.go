@@ -19,38 +19,84 @@
 package magneturi
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"iter"
+	"net"
+	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
+const magnetURISchemaPrefix = "magnet:?"
+
+// Prefix is the prefix of a parameter in a Magnet URI, like "xt". It is a
+// string, so untyped string constants like "xt" can be used as prefixes too.
+type Prefix string
+
+// The prefixes of the parameters built into the package.
 const (
-	magnetURISchemaPrefix = "magnet:?"
-	exactTopicPrefix      = "xt"
-	displayNamePrefix     = "dn"
-	keywordTopicPrefix    = "kt"
-	manifestTopicPrefix   = "mt"
+	ExactTopic       Prefix = "xt"
+	DisplayName      Prefix = "dn"
+	KeywordTopic     Prefix = "kt"
+	ManifestTopic    Prefix = "mt"
+	AddressTracker   Prefix = "tr"
+	ExactLength      Prefix = "xl"
+	WebSeed          Prefix = "ws"
+	ExactSource      Prefix = "xs"
+	AcceptableSource Prefix = "as"
+	Peer             Prefix = "x.pe"
+	SelectOnly       Prefix = "so"
+	DHTNode          Prefix = "dht"
+	ExtendedDHTNode  Prefix = "x.dht"
 )
 
 // MagnetURI represents a uniform resource identifier following the magnet scheme.
+//
+// The methods that read or modify the parameters in place take a pointer
+// receiver. The methods that compare Magnet URIs, return a new Magnet URI or
+// encode it take a value receiver, so they can be called on values that are
+// not addressable, such as the result of a function call. Both sets are in
+// the method set of *MagnetURI.
 type MagnetURI struct {
 	Parameters []Parameter
 }
 
 // Parameter represents a parameter in a Magnet URI.
 type Parameter struct {
-	Prefix string
+	Prefix Prefix
 	Index  int // 0 means there is no index specified for the parameter.
 	Value  string
 }
 
 // ExactTopics returns the list of exact topic parameters of the Magnet URI.
 func (magnetURI *MagnetURI) ExactTopics() []Parameter {
-	return magnetURI.parametersByPrefix(exactTopicPrefix)
+	return magnetURI.parametersByPrefix(ExactTopic)
+}
+
+// PrimaryExactTopic returns the exact topic parameter of the Magnet URI with
+// the lowest index, where a parameter without index is the lowest, and true
+// if there is one. If several exact topics have the lowest index, the first
+// one is returned.
+func (magnetURI *MagnetURI) PrimaryExactTopic() (Parameter, bool) {
+	exactTopics := magnetURI.ExactTopics()
+	if len(exactTopics) == 0 {
+		return Parameter{}, false
+	}
+	primary := exactTopics[0]
+	for _, exactTopic := range exactTopics[1:] {
+		if exactTopic.Index < primary.Index {
+			primary = exactTopic
+		}
+	}
+	return primary, true
 }
 
-func (magnetURI *MagnetURI) parametersByPrefix(prefix string) []Parameter {
+func (magnetURI *MagnetURI) parametersByPrefix(prefix Prefix) []Parameter {
 	prefixParameters := make([]Parameter, 0, len(magnetURI.Parameters))
 	for _, parameter := range magnetURI.Parameters {
 		if parameter.Prefix == prefix {
@@ -60,19 +106,318 @@ func (magnetURI *MagnetURI) parametersByPrefix(prefix string) []Parameter {
 	return prefixParameters
 }
 
+// Len returns the number of parameters of the Magnet URI.
+func (magnetURI *MagnetURI) Len() int {
+	return len(magnetURI.Parameters)
+}
+
+// Count returns the number of parameters of the Magnet URI with the prefix.
+func (magnetURI *MagnetURI) Count(prefix Prefix) int {
+	count := 0
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == prefix {
+			count++
+		}
+	}
+	return count
+}
+
+// RangeByPrefix calls fn for each parameter of the Magnet URI with the prefix,
+// in order, until fn returns false. Unlike the accessors that return a list,
+// it doesn't allocate.
+func (magnetURI *MagnetURI) RangeByPrefix(prefix Prefix, fn func(Parameter) bool) {
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == prefix && !fn(parameter) {
+			return
+		}
+	}
+}
+
+// All returns an iterator over the parameters of the Magnet URI, in order.
+func (magnetURI *MagnetURI) All() iter.Seq[Parameter] {
+	return func(yield func(Parameter) bool) {
+		for _, parameter := range magnetURI.Parameters {
+			if !yield(parameter) {
+				return
+			}
+		}
+	}
+}
+
+// ByPrefix returns an iterator over the parameters of the Magnet URI with the
+// prefix, in order. Like RangeByPrefix, it doesn't allocate a list.
+func (magnetURI *MagnetURI) ByPrefix(prefix Prefix) iter.Seq[Parameter] {
+	return func(yield func(Parameter) bool) {
+		magnetURI.RangeByPrefix(prefix, yield)
+	}
+}
+
 // DisplayNames returns the list of display name parameters of the Magnet URI.
 func (magnetURI *MagnetURI) DisplayNames() []Parameter {
-	return magnetURI.parametersByPrefix(displayNamePrefix)
+	return magnetURI.parametersByPrefix(DisplayName)
+}
+
+// SuggestedFilename returns a name that is safe to use as a file name in a
+// download directory, built from the decoded display name of the Magnet URI,
+// and true if there is such a name.
+// Path separators are replaced with "_", control characters are removed, and
+// the names "." and ".." are rejected, so the file can't escape the directory.
+func (magnetURI *MagnetURI) SuggestedFilename() (string, bool) {
+	displayName, ok := magnetURI.DisplayName()
+	if !ok {
+		return "", false
+	}
+	filename := strings.Map(func(r rune) rune {
+		switch {
+		case r == '/' || r == '\\':
+			return '_'
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, displayName)
+	filename = strings.TrimSpace(filename)
+	if filename == "" || filename == "." || filename == ".." {
+		return "", false
+	}
+	return filename, true
+}
+
+// IsDirectoryName returns true if the decoded display name of the Magnet URI
+// contains a path separator, which means that it names a directory instead of
+// a file.
+func (magnetURI *MagnetURI) IsDirectoryName() bool {
+	displayName, ok := magnetURI.DisplayName()
+	return ok && strings.ContainsAny(displayName, "/\\")
+}
+
+// DisplayName returns the first display name of the Magnet URI decoded, with
+// the "+" characters converted to spaces and the percent-encoded sequences
+// decoded, and true if it is present and can be decoded.
+func (magnetURI *MagnetURI) DisplayName() (string, bool) {
+	displayNames := magnetURI.DisplayNames()
+	if len(displayNames) == 0 {
+		return "", false
+	}
+	displayName, err := displayNames[0].DecodedValue()
+	if err != nil {
+		return "", false
+	}
+	return displayName, true
 }
 
 // KeywordTopics returns the list of keyword topic parameters of the Magnet URI.
 func (magnetURI *MagnetURI) KeywordTopics() []Parameter {
-	return magnetURI.parametersByPrefix(keywordTopicPrefix)
+	return magnetURI.parametersByPrefix(KeywordTopic)
+}
+
+// Keywords returns the list of search keywords of the keyword topic
+// parameters of the Magnet URI. The keyword topic values are split on "+" and
+// every keyword is percent-decoded. Empty and repeated keywords are skipped.
+func (magnetURI *MagnetURI) Keywords() []string {
+	keywords := []string{}
+	seen := make(map[string]bool)
+	for _, keywordTopic := range magnetURI.KeywordTopics() {
+		for _, term := range strings.Split(keywordTopic.Value, "+") {
+			keyword, err := url.QueryUnescape(term)
+			if err != nil {
+				keyword = term
+			}
+			if keyword == "" || seen[keyword] {
+				continue
+			}
+			seen[keyword] = true
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
 }
 
 // ManifestTopics returns the list of manifest topic parameters of the Magnet URI.
 func (magnetURI *MagnetURI) ManifestTopics() []Parameter {
-	return magnetURI.parametersByPrefix(manifestTopicPrefix)
+	return magnetURI.parametersByPrefix(ManifestTopic)
+}
+
+// Trackers returns the list of address tracker parameters of the Magnet URI.
+func (magnetURI *MagnetURI) Trackers() []Parameter {
+	return magnetURI.parametersByPrefix(AddressTracker)
+}
+
+// IsTrackerless returns true if the Magnet URI has an exact topic but no
+// address trackers, so the peers can only be found through the DHT, peer
+// exchange or the web seeds, false if not.
+func (magnetURI *MagnetURI) IsTrackerless() bool {
+	return len(magnetURI.ExactTopics()) != 0 && len(magnetURI.Trackers()) == 0
+}
+
+// AddTrackers returns a copy of the Magnet URI with an address tracker
+// parameter appended for each of the URLs, skipping the URLs that are already
+// trackers of the Magnet URI. If the trackers of the Magnet URI are indexed,
+// like "tr.1", the new ones are numbered after the highest index.
+func (magnetURI MagnetURI) AddTrackers(urls []string) MagnetURI {
+	parameters := make([]Parameter, len(magnetURI.Parameters),
+		len(magnetURI.Parameters)+len(urls))
+	copy(parameters, magnetURI.Parameters)
+	present := make(map[string]bool)
+	maxIndex := 0
+	for _, tracker := range magnetURI.Trackers() {
+		present[tracker.Value] = true
+		if tracker.Index > maxIndex {
+			maxIndex = tracker.Index
+		}
+	}
+	for _, u := range urls {
+		if u == "" || present[u] {
+			continue
+		}
+		present[u] = true
+		index := 0
+		if maxIndex != 0 {
+			maxIndex++
+			index = maxIndex
+		}
+		parameters = append(parameters, Parameter{AddressTracker, index, u})
+	}
+	return MagnetURI{Parameters: parameters}
+}
+
+// WebSeeds returns the list of web seed parameters of the Magnet URI.
+func (magnetURI *MagnetURI) WebSeeds() []Parameter {
+	return magnetURI.parametersByPrefix(WebSeed)
+}
+
+// ExactSources returns the list of exact source parameters of the Magnet URI.
+func (magnetURI *MagnetURI) ExactSources() []Parameter {
+	return magnetURI.parametersByPrefix(ExactSource)
+}
+
+// AcceptableSources returns the list of acceptable source parameters of the
+// Magnet URI.
+func (magnetURI *MagnetURI) AcceptableSources() []Parameter {
+	return magnetURI.parametersByPrefix(AcceptableSource)
+}
+
+// Peers returns the list of peer address parameters of the Magnet URI.
+func (magnetURI *MagnetURI) Peers() []Parameter {
+	return magnetURI.parametersByPrefix(Peer)
+}
+
+// DHTNodes returns the list of "host:port" addresses of the DHT bootstrap
+// nodes of the Magnet URI, from both the "dht" and the "x.dht" parameters.
+func (magnetURI *MagnetURI) DHTNodes() []string {
+	var nodes []string
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == DHTNode ||
+			parameter.Prefix == ExtendedDHTNode {
+			nodes = append(nodes, parameter.Value)
+		}
+	}
+	return nodes
+}
+
+func validateDHTNode(value string) error {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		return errors.New("The host is empty")
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return errors.New(fmt.Sprintf("Wrong port: %q", port))
+	}
+	return nil
+}
+
+// ExactLength returns the exact length in bytes of the Magnet URI content, and
+// true if the exact length parameter is present.
+func (magnetURI *MagnetURI) ExactLength() (int64, bool) {
+	exactLengths := magnetURI.parametersByPrefix(ExactLength)
+	if len(exactLengths) == 0 {
+		return 0, false
+	}
+	exactLength, err := parseExactLength(exactLengths[0].Value)
+	if err != nil {
+		return 0, false
+	}
+	return exactLength, true
+}
+
+func parseExactLength(value string) (int64, error) {
+	exactLength, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if exactLength < 0 {
+		return 0, errors.New("The exact length is negative")
+	}
+	return exactLength, nil
+}
+
+// maxSelectedFileIndices is the largest number of file indices returned by
+// SelectedFileIndices, so a huge range can't exhaust the memory.
+const maxSelectedFileIndices = 1 << 20
+
+// SelectedFileIndices returns the sorted list of file indices selected by the
+// select only parameters of the Magnet URI. The select only values are comma
+// separated lists of indices and ranges, like "0,2,4-6". The ranges can't
+// select more than 2^20 file indices in total.
+func (magnetURI *MagnetURI) SelectedFileIndices() ([]int, error) {
+	selected := make(map[int]bool)
+	for _, selectOnly := range magnetURI.parametersByPrefix(SelectOnly) {
+		for _, item := range strings.Split(selectOnly.Value, ",") {
+			first, last, err := parseFileIndexRange(item)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Wrong select only file index: %q; %w", item, err)
+			}
+			if last-first >= maxSelectedFileIndices-len(selected) {
+				return nil, errors.New(fmt.Sprintf(
+					"Too many selected file indices in %q; the maximum "+
+						"is %d", item, maxSelectedFileIndices))
+			}
+			// The loop breaks before incrementing the last index, so it
+			// doesn't overflow when the last index is the largest int.
+			for index := first; ; index++ {
+				selected[index] = true
+				if index == last {
+					break
+				}
+			}
+		}
+	}
+	indices := make([]int, 0, len(selected))
+	for index := range selected {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
+func parseFileIndexRange(item string) (int, int, error) {
+	bounds := strings.SplitN(item, "-", 2)
+	first, err := parseFileIndex(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(bounds) == 1 {
+		return first, first, nil
+	}
+	last, err := parseFileIndex(bounds[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if last < first {
+		return 0, 0, errors.New("The range end is lower than its start")
+	}
+	return first, last, nil
+}
+
+func parseFileIndex(index string) (int, error) {
+	if index == "" {
+		return 0, errors.New("The file index is empty")
+	}
+	return strconv.Atoi(index)
 }
 
 // Equal returns true if the Magnet URIs are equal, false if not.
@@ -81,6 +426,205 @@ func (magnetURI MagnetURI) Equal(x MagnetURI) bool {
 	return compareParameters(magnetURI.Parameters, x.Parameters)
 }
 
+// EqualString parses the raw Magnet URI string and returns true if it is
+// equal to the Magnet URI, like Equal, false if not. The error of Parse is
+// returned if the raw string can't be parsed.
+func (magnetURI MagnetURI) EqualString(rawMagnetURI string) (bool, error) {
+	x, err := Parse(rawMagnetURI)
+	if err != nil {
+		return false, err
+	}
+	return magnetURI.Equal(x), nil
+}
+
+// EqualPtr is like Equal, but it takes both Magnet URIs by pointer to avoid
+// copying them. A nil Magnet URI is equal to an empty one.
+func (magnetURI *MagnetURI) EqualPtr(x *MagnetURI) bool {
+	var first, second []Parameter
+	if magnetURI != nil {
+		first = magnetURI.Parameters
+	}
+	if x != nil {
+		second = x.Parameters
+	}
+	return compareParameters(first, second)
+}
+
+// Get returns the value of the first parameter with the prefix and the index,
+// and true if it is present. Index 0 matches a parameter without index.
+func (magnetURI *MagnetURI) Get(prefix Prefix, index int) (string, bool) {
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == prefix && parameter.Index == index {
+			return parameter.Value, true
+		}
+	}
+	return "", false
+}
+
+// AddParameter appends the parameter to the Magnet URI after checking it like
+// Parse does: the prefix must be known, the index must be 0 or positive, and
+// the exact length and DHT node values must be well formed. Prefixes are
+// case sensitive here, so they must be lowercase.
+func (magnetURI *MagnetURI) AddParameter(parameter Parameter) error {
+	if parameter.Index < 0 || parameter.Index >= 1<<maxIndexBits {
+		return errors.New(
+			fmt.Sprintf("Wrong index for parameter %q: %d",
+				parameter.Prefix, parameter.Index))
+	}
+	added, err := addParameterToMagnetURI(
+		parameter.Prefix, parameter.Index, parameter.Value, *magnetURI)
+	if err != nil {
+		return err
+	}
+	magnetURI.Parameters = added.Parameters
+	return nil
+}
+
+// RemoveByPrefix removes all the parameters with the prefix, and returns the
+// number of parameters removed.
+func (magnetURI *MagnetURI) RemoveByPrefix(prefix Prefix) int {
+	parameters := magnetURI.Parameters[:0]
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix != prefix {
+			parameters = append(parameters, parameter)
+		}
+	}
+	removed := len(magnetURI.Parameters) - len(parameters)
+	magnetURI.Parameters = parameters
+	return removed
+}
+
+// RemoveByIndex removes the parameters with the prefix and the index, and
+// returns true if any parameter was removed.
+func (magnetURI *MagnetURI) RemoveByIndex(prefix Prefix, index int) bool {
+	parameters := magnetURI.Parameters[:0]
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix != prefix || parameter.Index != index {
+			parameters = append(parameters, parameter)
+		}
+	}
+	removed := len(magnetURI.Parameters) != len(parameters)
+	magnetURI.Parameters = parameters
+	return removed
+}
+
+// Renumber reassigns the indices of the parameters in place, keeping their
+// order: the parameters with a prefix that appears more than once are
+// numbered contiguously from 1, like "tr.1" and "tr.2", and the parameters
+// with a prefix that appears once are left without index. It returns the
+// number of parameters with a new index.
+func (magnetURI *MagnetURI) Renumber() int {
+	return len(renumberParameters(magnetURI.Parameters))
+}
+
+// Dedup removes the parameters that are identical in prefix, index and value
+// to a previous parameter, keeping the first occurrence.
+func (magnetURI *MagnetURI) Dedup() {
+	parameters := make([]Parameter, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		if !containsParameter(parameters, parameter) {
+			parameters = append(parameters, parameter)
+		}
+	}
+	magnetURI.Parameters = parameters
+}
+
+// Merge returns a new Magnet URI with the parameters of both Magnet URIs,
+// without the identical ones. Exact topics with different values are all
+// kept.
+func (magnetURI MagnetURI) Merge(other MagnetURI) MagnetURI {
+	parameters := make(
+		[]Parameter, 0, len(magnetURI.Parameters)+len(other.Parameters))
+	parameters = append(parameters, magnetURI.Parameters...)
+	parameters = append(parameters, other.Parameters...)
+	merged := MagnetURI{Parameters: parameters}
+	merged.Dedup()
+	return merged
+}
+
+// EqualContent returns true if the Magnet URIs have the same exact topics,
+// false if not. The order of the exact topics is not important, and the rest
+// of the parameters, like display names and trackers, are ignored.
+func (magnetURI MagnetURI) EqualContent(x MagnetURI) bool {
+	return compareParameters(magnetURI.ExactTopics(), x.ExactTopics())
+}
+
+// EqualIgnoringIndex returns true if the Magnet URIs have the same parameters
+// when their indices are ignored, false if not. The parameters are compared
+// as multisets of prefixes and values, so "xt.1=a&xt.2=b" is equal to
+// "xt=b&xt=a", but not to "xt=a".
+func (magnetURI MagnetURI) EqualIgnoringIndex(x MagnetURI) bool {
+	if len(magnetURI.Parameters) != len(x.Parameters) {
+		return false
+	}
+	counts := make(map[Parameter]int, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		counts[Parameter{parameter.Prefix, 0, parameter.Value}]++
+	}
+	for _, parameter := range x.Parameters {
+		key := Parameter{parameter.Prefix, 0, parameter.Value}
+		if counts[key] == 0 {
+			return false
+		}
+		counts[key]--
+	}
+	return true
+}
+
+// IsSupersetOf returns true if every parameter of the other Magnet URI is in
+// the Magnet URI, false if not. Unlike Equal, the Magnet URI can have more
+// parameters, like more address trackers.
+func (magnetURI MagnetURI) IsSupersetOf(other MagnetURI) bool {
+	for _, parameter := range other.Parameters {
+		if !containsParameter(magnetURI.Parameters, parameter) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the parameters that are only in the Magnet URI, and the
+// parameters that are only in the other Magnet URI.
+func (magnetURI MagnetURI) Diff(other MagnetURI) (onlyInFirst, onlyInSecond []Parameter) {
+	onlyInFirst = parametersNotIn(magnetURI.Parameters, other.Parameters)
+	onlyInSecond = parametersNotIn(other.Parameters, magnetURI.Parameters)
+	return
+}
+
+// TrackerDiff returns the address tracker URLs that are in the new Magnet URI
+// but not in the old one, and the ones that are in the old Magnet URI but not
+// in the new one. The indices of the trackers are ignored.
+func TrackerDiff(old, new MagnetURI) (added, removed []string) {
+	added = trackersNotIn(new.Trackers(), old.Trackers())
+	removed = trackersNotIn(old.Trackers(), new.Trackers())
+	return
+}
+
+func trackersNotIn(trackers []Parameter, list []Parameter) []string {
+	present := make(map[string]bool)
+	for _, tracker := range list {
+		present[tracker.Value] = true
+	}
+	var notIn []string
+	for _, tracker := range trackers {
+		if !present[tracker.Value] {
+			present[tracker.Value] = true
+			notIn = append(notIn, tracker.Value)
+		}
+	}
+	return notIn
+}
+
+func parametersNotIn(parameters []Parameter, list []Parameter) []Parameter {
+	var notIn []Parameter
+	for _, parameter := range parameters {
+		if !containsParameter(list, parameter) {
+			notIn = append(notIn, parameter)
+		}
+	}
+	return notIn
+}
+
 func compareParameters(first []Parameter, second []Parameter) bool {
 	if len(first) == len(second) {
 		for _, parameter := range first {
@@ -105,105 +649,506 @@ func containsParameter(list []Parameter, parameter Parameter) bool {
 }
 
 // Parse parses a raw Magnet URI string into a MagnetURI structure.
+// The errors are returned as *ParseError, with the raw string.
+// The parameter values are stored encoded, as they appear in the raw string.
+// Use Parameter.DecodedValue to get the decoded values.
+// Whitespace around the raw string and a trailing "#fragment" are ignored.
+// The "&amp;" HTML entities, found in Magnet URIs copied from HTML, are
+// decoded to "&" separators.
+// The parameters can be separated by "&" or by ";". A ";" that is not
+// followed by a known prefix and a "=" is kept as part of the value.
+// URL values, like web seeds, should have their "&" and ";" characters
+// percent-encoded. When they don't, the parts that follow them and don't
+// start with a known prefix are kept as part of the URL.
 func Parse(rawMagnetURI string) (MagnetURI, error) {
-	if strings.HasPrefix(rawMagnetURI, magnetURISchemaPrefix) {
-		rawMagnetURIWithoutPrefix := strings.TrimPrefix(
-			rawMagnetURI, magnetURISchemaPrefix)
-		parameters := strings.Split(rawMagnetURIWithoutPrefix, "&")
-		return parseParameters(parameters)
+	magnetURI, _, err := parse(rawMagnetURI, ParseOptions{})
+	return magnetURI, err
+}
+
+// parse parses the raw Magnet URI like ParseWithOptions, and returns also the
+// raw parameters, in the same order as the parsed parameters.
+func parse(rawMagnetURI string, options ParseOptions) (MagnetURI, []queryParameter, error) {
+	query, offset, err := trimSchemaPrefix(rawMagnetURI)
+	if err != nil {
+		return MagnetURI{}, nil, &ParseError{rawMagnetURI, err.Error(), err}
+	}
+	return parseQuery(rawMagnetURI, query, offset, options)
+}
+
+// parseQuery parses the query of the raw string, at the byte offset, and
+// returns also the raw parameters. The errors are returned as *ParseError,
+// with the raw string.
+func parseQuery(raw string, query string, offset int, options ParseOptions) (MagnetURI, []queryParameter, error) {
+	parameters := splitQuery(query, offset, options)
+	magnetURI, _, err := parseParameters(parameters, options)
+	if err != nil {
+		return MagnetURI{}, nil, &ParseError{raw, err.Error(), err}
+	}
+	return magnetURI, parameters, nil
+}
+
+// trimSchemaPrefix returns the query of the raw Magnet URI, without the
+// surrounding whitespace and the schema prefix, and its byte offset in the
+// raw string.
+func trimSchemaPrefix(rawMagnetURI string) (string, int, error) {
+	trimmed := strings.TrimSpace(rawMagnetURI)
+	if !strings.HasPrefix(trimmed, magnetURISchemaPrefix) {
+		return "", 0, &SchemaPrefixError{}
+	}
+	offset := len(rawMagnetURI) -
+		len(strings.TrimLeftFunc(rawMagnetURI, unicode.IsSpace)) +
+		len(magnetURISchemaPrefix)
+	return trimmed[len(magnetURISchemaPrefix):], offset, nil
+}
+
+// ParseQuery parses the query of a Magnet URI, the part after "magnet:?",
+// into a MagnetURI structure. The parameters are parsed like in Parse, and the
+// errors are returned as *ParseError too, with the query as the raw string.
+func ParseQuery(query string) (MagnetURI, error) {
+	magnetURI, _, err := parseQuery(query, query, 0, ParseOptions{})
+	return magnetURI, err
+}
+
+// ParseLenient parses a raw Magnet URI string into a MagnetURI structure like
+// Parse, but the parameters that can't be parsed are skipped instead of making
+// the whole Magnet URI fail. The errors of the skipped parameters are
+// returned.
+func ParseLenient(rawMagnetURI string) (MagnetURI, []error) {
+	query, offset, err := trimSchemaPrefix(rawMagnetURI)
+	if err != nil {
+		return MagnetURI{}, []error{err}
 	}
-	return MagnetURI{}, errors.New(
-		fmt.Sprintf(
-			"The string doesn't start with the Magnet URI schema prefix %q",
-			magnetURISchemaPrefix))
+	magnetURI, errs, _ := parseParameters(
+		splitQuery(query, offset, ParseOptions{}), ParseOptions{Lenient: true})
+	return magnetURI, errs
+}
+
+// queryParameter is a parameter of a Magnet URI query, not parsed yet.
+type queryParameter struct {
+	text      string
+	pos       int  // The byte offset of the parameter in the raw string.
+	separator byte // The separator before the parameter, "&" or ";".
 }
 
-func parseParameters(parameters []string) (magnetURI MagnetURI, err error) {
+// splitQuery splits the query of a Magnet URI into its parameters, separated
+// by "&" or by the ";" of some old generators when it is followed by a known
+// prefix, ignoring surrounding whitespace
+// and a trailing "#fragment", and decoding the "&amp;" separators, unless the
+// options disallow them. The offset is the byte offset of the query in the raw
+// string.
+func splitQuery(query string, offset int, options ParseOptions) []queryParameter {
+	trimmed := strings.TrimLeftFunc(query, unicode.IsSpace)
+	offset += len(query) - len(trimmed)
+	query = strings.TrimRightFunc(trimmed, unicode.IsSpace)
+	if i := strings.Index(query, "#"); i >= 0 {
+		query = query[:i]
+	}
+	var parameters []queryParameter
+	start := 0
+	separator := byte('&')
+	for i := 0; i <= len(query); i++ {
+		if i < len(query) && query[i] != '&' &&
+			(query[i] != ';' || options.DisallowSemicolonSeparators ||
+				!isSemicolonSeparator(query[i+1:])) {
+			continue
+		}
+		parameters = append(parameters,
+			queryParameter{query[start:i], offset + start, separator})
+		if i == len(query) {
+			break
+		}
+		separator = query[i]
+		if !options.DisallowHTMLEntities &&
+			strings.HasPrefix(query[i:], "&amp;") {
+			i += len("&amp;") - 1
+		}
+		start = i + 1
+	}
+	return joinURLValues(parameters)
+}
+
+// isSemicolonSeparator returns true if a ";" followed by the rest of the
+// query separates parameters, because the rest starts with a known prefix and
+// a "=". Otherwise the ";" is part of a value, like a display name.
+func isSemicolonSeparator(rest string) bool {
+	if i := strings.IndexAny(rest, "&;"); i >= 0 {
+		rest = rest[:i]
+	}
+	return hasValidPrefix(rest)
+}
+
+// urlValuePrefixes are the prefixes with URL values, that can have their own
+// query with "&" or ";" separators if they are not percent-encoded.
+var urlValuePrefixes = []Prefix{
+	ManifestTopic, AddressTracker, WebSeed, ExactSource,
+	AcceptableSource,
+}
+
+// joinURLValues joins back the parts of URL values that were split on "&" or
+// ";".
+// A part that doesn't start with a known prefix, and that follows a parameter
+// with a URL value, is part of that URL.
+func joinURLValues(parameters []queryParameter) []queryParameter {
+	joined := make([]queryParameter, 0, len(parameters))
 	for _, parameter := range parameters {
-		magnetURI, err = parseParameter(parameter, magnetURI)
+		if len(joined) != 0 && !hasValidPrefix(parameter.text) &&
+			hasURLValue(joined[len(joined)-1].text) {
+			joined[len(joined)-1].text +=
+				string(parameter.separator) + parameter.text
+			continue
+		}
+		joined = append(joined, parameter)
+	}
+	return joined
+}
+
+func hasValidPrefix(parameter string) bool {
+	parameterSplit := strings.SplitN(parameter, "=", 2)
+	if len(parameterSplit) != 2 {
+		return false
+	}
+	prefix, _, err := splitPrefixIndex(parameterSplit[0])
+	return err == nil && isValidPrefix(prefix)
+}
+
+func hasURLValue(parameter string) bool {
+	parameterSplit := strings.SplitN(parameter, "=", 2)
+	if len(parameterSplit) != 2 {
+		return false
+	}
+	prefix, _, err := splitPrefixIndex(parameterSplit[0])
+	return err == nil && isURLValuePrefix(prefix)
+}
+
+func isURLValuePrefix(prefix Prefix) bool {
+	for _, urlValuePrefix := range urlValuePrefixes {
+		if prefix == urlValuePrefix {
+			return true
+		}
+	}
+	return false
+}
+
+// parseParameters parses the parameters of a query. With options.Lenient,
+// the parameters that can't be parsed are skipped, and their errors are
+// returned in the list of skipped errors.
+func parseParameters(parameters []queryParameter, options ParseOptions) (MagnetURI, []error, error) {
+	if options.MaxParameters > 0 && len(parameters) > options.MaxParameters {
+		return MagnetURI{}, nil, &PositionError{
+			parameters[options.MaxParameters].pos,
+			errors.New(fmt.Sprintf(
+				"Too many parameters: %d; the maximum is %d",
+				len(parameters), options.MaxParameters))}
+	}
+	var magnetURI MagnetURI
+	var skipped []error
+	for _, parameter := range parameters {
+		parsedMagnetURI, err := parseParameter(
+			parameter.text, magnetURI, options)
 		if err != nil {
-			magnetURI = MagnetURI{}
+			if options.Lenient {
+				skipped = append(skipped, &PositionError{parameter.pos, err})
+				continue
+			}
+			return MagnetURI{}, nil, &PositionError{parameter.pos, err}
 		}
+		magnetURI = parsedMagnetURI
 	}
-	return
+	return magnetURI, skipped, nil
 }
 
-func parseParameter(parameter string, magnetURI MagnetURI) (MagnetURI, error) {
+func parseParameter(parameter string, magnetURI MagnetURI, options ParseOptions) (MagnetURI, error) {
+	// Only the first "=" separates the prefix from the value, so values that
+	// are URLs with their own query keep all their "=" characters.
 	parameterSplit := strings.SplitN(parameter, "=", 2)
 	if len(parameterSplit) != 2 {
-		return MagnetURI{}, errors.New(
-			fmt.Sprintf("Parameter without prefix: %q", parameter))
+		return MagnetURI{}, &MissingPrefixError{parameter}
 	}
-	prefix := parameterSplit[0]
-	prefix, index, err := splitPrefixIndex(prefix)
+	prefix, index, err := splitPrefixIndex(parameterSplit[0])
 	if err != nil {
-		return MagnetURI{}, errors.New(
-			fmt.Sprintf(
-			    "Wrong parameter prefix: %q; %s", prefix, err.Error()))
+		return MagnetURI{}, fmt.Errorf(
+			"Wrong parameter prefix: %q; %w", parameterSplit[0], err)
 	}
 	value := parameterSplit[1]
+	if options.ValueTransform != nil {
+		value, err = options.ValueTransform(prefix, value)
+		if err != nil {
+			return MagnetURI{}, fmt.Errorf(
+				"Wrong value of parameter %q; %w", parameterSplit[0], err)
+		}
+	}
 	return addParameterToMagnetURI(prefix, index, value, magnetURI)
 }
 
-func splitPrefixIndex(prefix string) (string, int, error) {
+// splitPrefixIndex splits the prefix from its index. Prefixes are case
+// insensitive, so the returned prefix is lowercase.
+func splitPrefixIndex(rawPrefix string) (Prefix, int, error) {
+	prefix := strings.ToLower(rawPrefix)
+	if isValidPrefix(Prefix(prefix)) {
+		return Prefix(prefix), 0, nil
+	}
+	// Prefixes like "x.pe" contain a ".", so the part after it is not an
+	// index.
+	if i := strings.LastIndex(prefix, "."); i >= 0 &&
+		strings.Contains(prefix[:i], ".") && isValidPrefix(Prefix(prefix[:i])) {
+		index, err := parseIndex(prefix[i+1:])
+		if err != nil {
+			return "", 0, err
+		}
+		return Prefix(prefix[:i]), index, nil
+	}
 	if strings.Contains(prefix, ".") {
 		prefixSplit := strings.SplitN(prefix, ".", 2)
-		index, err := strconv.Atoi(prefixSplit[1])
+		index, err := parseIndex(prefixSplit[1])
 		if err != nil {
-			return "", index, err
+			return "", 0, err
 		}
-		return prefixSplit[0], index, nil
+		return Prefix(prefixSplit[0]), index, nil
+	}
+	return Prefix(prefix), 0, nil
+}
+
+// maxIndexBits is the bit size of the largest index accepted.
+const maxIndexBits = 31
+
+// parseIndex parses the index of a prefix, that must be a positive number
+// lower than 2^31.
+func parseIndex(s string) (int, error) {
+	if s == "" {
+		return 0, errors.New("The index is empty")
+	}
+	if strings.HasPrefix(s, "-") {
+		return 0, errors.New("The index is negative")
+	}
+	index, err := strconv.ParseUint(s, 10, maxIndexBits)
+	if err != nil {
+		return 0, err
+	}
+	if index == 0 {
+		return 0, errors.New("The index is zero")
 	}
-	return prefix, 0, nil
+	return int(index), nil
 }
 
-func addParameterToMagnetURI(prefix string, index int, value string, magnetURI MagnetURI) (MagnetURI, error) {
+func addParameterToMagnetURI(prefix Prefix, index int, value string, magnetURI MagnetURI) (MagnetURI, error) {
 	if !isValidPrefix(prefix) {
-		return MagnetURI{}, errors.New(
-		    fmt.Sprintf("Unknown parameter prefix: %q", prefix))
+		return MagnetURI{}, &UnknownPrefixError{string(prefix)}
+	}
+	if prefix == ExactLength {
+		if _, err := parseExactLength(value); err != nil {
+			return MagnetURI{}, fmt.Errorf(
+				"Wrong exact length: %q; %w", value, err)
+		}
+	}
+	if prefix == DHTNode || prefix == ExtendedDHTNode {
+		if err := validateDHTNode(value); err != nil {
+			return MagnetURI{}, fmt.Errorf(
+				"Wrong DHT node: %q; %w", value, err)
+		}
 	}
 	var parameter = Parameter{prefix, index, value}
 	magnetURI.Parameters = append(magnetURI.Parameters, parameter)
 	return magnetURI, nil
 }
 
-func isValidPrefix(prefix string) bool {
-	return prefix == exactTopicPrefix || prefix == displayNamePrefix ||
-		prefix == keywordTopicPrefix || prefix == manifestTopicPrefix
-}
-
 // String reassembles the MagnetURI into a valid MagnetURI string.
+// The values are written as they are stored, escaping only the characters
+// that can't appear in a Magnet URI, as described in Parameter.String, so
+// Parse returns the same Magnet URI only if they are encoded; use
+// NewParameter to build parameters from values with characters like "&", "="
+// or "+".
 func (magnetURI *MagnetURI) String() (string, error) {
-	if !magnetURI.hasParameters() {
-		err := errors.New("The Magnet URI has no parameters.")
+	s, err := magnetURI.AppendTo(make([]byte, 0, magnetURI.SerializedLen()))
+	if err != nil {
 		return "", err
 	}
-	parameterStrings := magnetURI.parameterStrings()
-	parameters := strings.Join(parameterStrings, "&")
-	s := fmt.Sprintf("%s%s", magnetURISchemaPrefix, parameters)
-	return s, nil
+	return string(s), nil
 }
 
-func (magnetURI *MagnetURI) hasParameters() bool {
-	if len(magnetURI.Parameters) != 0 {
-		return true
+// MustString returns the string form of the Magnet URI like String, or ""
+// if it has no parameters. It panics if String returns any other error, which
+// doesn't happen with the current serialization, so it's safe to use with
+// any Magnet URI.
+func (magnetURI *MagnetURI) MustString() string {
+	if magnetURI.IsEmpty() {
+		return ""
 	}
-	return false
+	s, err := magnetURI.String()
+	if err != nil {
+		panic(err)
+	}
+	return s
 }
 
-func (magnetURI *MagnetURI) parameterStrings() []string {
-	parameterStrings := make([]string, 0, len(magnetURI.Parameters))
-	for _, parameter := range magnetURI.Parameters {
-		parameterStrings = append(parameterStrings, parameter.String())
+// AppendTo appends the string form of the Magnet URI, as returned by String,
+// to dst and returns the extended buffer. If the Magnet URI has no
+// parameters, dst is returned unchanged with an error.
+func (magnetURI *MagnetURI) AppendTo(dst []byte) ([]byte, error) {
+	if magnetURI.IsEmpty() {
+		err := errors.New("The Magnet URI has no parameters.")
+		return dst, err
 	}
-	return parameterStrings
+	dst = append(dst, magnetURISchemaPrefix...)
+	for i := range magnetURI.Parameters {
+		if i != 0 {
+			dst = append(dst, '&')
+		}
+		dst = magnetURI.Parameters[i].appendTo(dst)
+	}
+	return dst, nil
+}
+
+// SerializedLen returns the length in bytes of the string returned by String,
+// so buffers can be allocated before writing several Magnet URIs. It returns
+// 0 if the Magnet URI has no parameters.
+func (magnetURI *MagnetURI) SerializedLen() int {
+	if magnetURI.IsEmpty() {
+		return 0
+	}
+	n := len(magnetURISchemaPrefix) + len(magnetURI.Parameters) - 1
+	for i := range magnetURI.Parameters {
+		n += magnetURI.Parameters[i].serializedLen()
+	}
+	return n
+}
+
+// invalidMagnetURIString is the formatted form of a Magnet URI that can't be
+// reassembled into a string.
+const invalidMagnetURIString = "<invalid magnet uri>"
+
+// Format implements the fmt.Formatter interface, so the Magnet URI is
+// formatted as its string form by the %v, %s and %q verbs, or as
+// "<invalid magnet uri>" if it has no parameters. The %#v verb formats the Go
+// syntax representation of the structure.
+func (magnetURI MagnetURI) Format(f fmt.State, verb rune) {
+	if verb == 'v' && f.Flag('#') {
+		fmt.Fprintf(f, "magneturi.MagnetURI{Parameters:%#v}",
+			magnetURI.Parameters)
+		return
+	}
+	s, err := magnetURI.String()
+	if err != nil {
+		s = invalidMagnetURIString
+	}
+	switch verb {
+	case 'v', 's':
+		fmt.Fprint(f, s)
+	case 'q':
+		fmt.Fprintf(f, "%q", s)
+	default:
+		fmt.Fprintf(f, "%%!%c(magneturi.MagnetURI=%s)", verb, s)
+	}
+}
+
+// canonicalPrefixOrder is the order of the known prefixes in the canonical
+// form of a Magnet URI. Unknown prefixes go after these, alphabetically.
+var canonicalPrefixOrder = []Prefix{
+	ExactTopic, ExactLength, DisplayName, KeywordTopic,
+	ManifestTopic, AddressTracker, WebSeed, ExactSource,
+	AcceptableSource, Peer, SelectOnly, DHTNode,
+	ExtendedDHTNode,
+}
+
+// Canonical reassembles the MagnetURI into a valid MagnetURI string with the
+// parameters sorted by prefix, in the canonical prefix order (xt, xl, dn, kt,
+// mt, tr, ws, xs, as, x.pe, so, dht, x.dht, and then the rest
+// alphabetically), by index
+// and by value. Magnet URIs that are Equal have the same canonical string.
+func (magnetURI *MagnetURI) Canonical() (string, error) {
+	parameters := make([]Parameter, len(magnetURI.Parameters))
+	copy(parameters, magnetURI.Parameters)
+	canonicalMagnetURI := MagnetURI{Parameters: parameters}
+	canonicalMagnetURI.Sort()
+	return canonicalMagnetURI.String()
+}
+
+// Sort sorts the parameters of the Magnet URI in place, in the same order
+// used by Canonical: by prefix in the canonical prefix order, by index and by
+// value. Parameters that are identical keep their relative order.
+func (magnetURI *MagnetURI) Sort() {
+	parameters := magnetURI.Parameters
+	sort.SliceStable(parameters, func(i, j int) bool {
+		return lessCanonicalParameter(parameters[i], parameters[j])
+	})
+}
+
+// Fingerprint returns the hex SHA-256 digest of the canonical string of the
+// Magnet URI, so Magnet URIs that are Equal have the same fingerprint.
+// A Magnet URI without parameters has the fingerprint of the empty string.
+func (magnetURI *MagnetURI) Fingerprint() string {
+	canonical, _ := magnetURI.Canonical()
+	digest := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(digest[:])
+}
+
+// ContentFingerprint returns the fingerprint of the exact topics of the
+// Magnet URI, so Magnet URIs that are EqualContent have the same content
+// fingerprint.
+func (magnetURI *MagnetURI) ContentFingerprint() string {
+	exactTopics := MagnetURI{Parameters: magnetURI.ExactTopics()}
+	return exactTopics.Fingerprint()
+}
+
+func lessCanonicalParameter(first Parameter, second Parameter) bool {
+	if first.Prefix != second.Prefix {
+		firstRank := canonicalPrefixRank(first.Prefix)
+		secondRank := canonicalPrefixRank(second.Prefix)
+		if firstRank != secondRank {
+			return firstRank < secondRank
+		}
+		return first.Prefix < second.Prefix
+	}
+	if first.Index != second.Index {
+		return first.Index < second.Index
+	}
+	return first.Value < second.Value
+}
+
+func canonicalPrefixRank(prefix Prefix) int {
+	for rank, canonicalPrefix := range canonicalPrefixOrder {
+		if prefix == canonicalPrefix {
+			return rank
+		}
+	}
+	return len(canonicalPrefixOrder)
+}
+
+// IsEmpty returns true if the Magnet URI has no parameters, in which case it
+// can't be reassembled into a string.
+func (magnetURI *MagnetURI) IsEmpty() bool {
+	return len(magnetURI.Parameters) == 0
 }
 
 // String reassembles the Parameter into a valid MagnetURI parameter string.
+// The index is written in its decimal form, so an index parsed from "xt.01"
+// is written back as "xt.1". The value is stored already encoded, and only
+// the characters that can't appear in a Magnet URI are escaped, with the
+// policy of the prefix: spaces are written as "+" in display names and
+// keyword topics and as "%20" in the rest, "&" is kept in URL values like
+// address trackers, and the ":" delimiters of exact topic URNs are kept.
+// Use NewParameter to encode a decoded value.
 func (parameter *Parameter) String() string {
+	return string(parameter.appendTo(
+		make([]byte, 0, parameter.serializedLen())))
+}
+
+func (parameter *Parameter) appendTo(dst []byte) []byte {
+	dst = append(dst, parameter.Prefix...)
+	if parameter.Index != 0 {
+		dst = append(dst, '.')
+		dst = strconv.AppendInt(dst, int64(parameter.Index), 10)
+	}
+	dst = append(dst, '=')
+	space, keep := escapePolicy(parameter.Prefix)
+	return appendEscapedValue(dst, parameter.Value, space, keep)
+}
+
+func (parameter *Parameter) serializedLen() int {
+	space, keep := escapePolicy(parameter.Prefix)
+	n := len(parameter.Prefix) + len("=") +
+		escapedValueLen(parameter.Value, space, keep)
 	if parameter.Index != 0 {
-		return fmt.Sprintf(
-			"%s.%d=%s", parameter.Prefix, parameter.Index, parameter.Value)
+		n += len(".") + len(strconv.Itoa(parameter.Index))
 	}
-	return fmt.Sprintf("%s=%s", parameter.Prefix, parameter.Value)
+	return n
 }
@@ -16,6 +16,11 @@
 package magneturi
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -131,6 +136,176 @@ var compareParametersScenarios = []compareParametersScenario{
 	},
 }
 
+func TestDiff(t *testing.T) {
+	scenarios := diffScenarios
+	for _, scenario := range scenarios {
+		first := MagnetURI{Parameters: scenario.FirstParameters}
+		second := MagnetURI{Parameters: scenario.SecondParameters}
+		onlyInFirst, onlyInSecond := first.Diff(second)
+		if !compareParameters(onlyInFirst, scenario.ExpectedOnlyInFirst) {
+			t.Errorf("Error on test %q: expected only in first %v; got %v",
+				scenario.Name, scenario.ExpectedOnlyInFirst, onlyInFirst)
+		}
+		if !compareParameters(onlyInSecond, scenario.ExpectedOnlyInSecond) {
+			t.Errorf("Error on test %q: expected only in second %v; got %v",
+				scenario.Name, scenario.ExpectedOnlyInSecond, onlyInSecond)
+		}
+	}
+}
+
+type diffScenario struct {
+	compareParametersScenario
+	ExpectedOnlyInFirst  []Parameter
+	ExpectedOnlyInSecond []Parameter
+}
+
+var diffScenarios = []diffScenario{
+	{
+		compareParametersScenario: compareParametersScenarios[0],
+		ExpectedOnlyInFirst:       []Parameter{},
+		ExpectedOnlyInSecond:      []Parameter{},
+	},
+	{
+		compareParametersScenario: compareParametersScenarios[2],
+		ExpectedOnlyInFirst:       []Parameter{},
+		ExpectedOnlyInSecond:      []Parameter{},
+	},
+	{
+		// Missing parameter.
+		compareParametersScenario: compareParametersScenarios[3],
+		ExpectedOnlyInFirst: []Parameter{
+			Parameter{"pref", 0, "param2"},
+		},
+		ExpectedOnlyInSecond: []Parameter{},
+	},
+	{
+		// Extra parameter.
+		compareParametersScenario: compareParametersScenarios[4],
+		ExpectedOnlyInFirst:       []Parameter{},
+		ExpectedOnlyInSecond: []Parameter{
+			Parameter{"pref", 0, "param3"},
+		},
+	},
+	{
+		// Wrong index.
+		compareParametersScenario: compareParametersScenarios[6],
+		ExpectedOnlyInFirst: []Parameter{
+			Parameter{"pref", 0, "param1"},
+		},
+		ExpectedOnlyInSecond: []Parameter{
+			Parameter{"pref", 1, "param1"},
+		},
+	},
+}
+
+func TestTrackerDiff(t *testing.T) {
+	scenarios := trackerDiffScenarios
+	for _, scenario := range scenarios {
+		added, removed := TrackerDiff(scenario.Old, scenario.New)
+		if !reflect.DeepEqual(added, scenario.ExpectedAdded) {
+			t.Errorf("Error on test %q: expected added %v; got %v",
+				scenario.Name, scenario.ExpectedAdded, added)
+		}
+		if !reflect.DeepEqual(removed, scenario.ExpectedRemoved) {
+			t.Errorf("Error on test %q: expected removed %v; got %v",
+				scenario.Name, scenario.ExpectedRemoved, removed)
+		}
+	}
+}
+
+type trackerDiffScenario struct {
+	Name            string
+	Old             MagnetURI
+	New             MagnetURI
+	ExpectedAdded   []string
+	ExpectedRemoved []string
+}
+
+var trackerDiffScenarios = []trackerDiffScenario{
+	{
+		Name: "Empty Magnet URIs",
+	},
+	{
+		Name: "Same trackers with different indices",
+		Old: MagnetURI{[]Parameter{
+			Parameter{"tr", 0, "http://tracker1.example/announce"},
+			Parameter{"tr", 0, "http://tracker2.example/announce"},
+		}},
+		New: MagnetURI{[]Parameter{
+			Parameter{"tr", 2, "http://tracker1.example/announce"},
+			Parameter{"tr", 1, "http://tracker2.example/announce"},
+		}},
+	},
+	{
+		Name: "Added and removed trackers",
+		Old: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 1, "http://tracker1.example/announce"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+		}},
+		New: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 1, "http://tracker2.example/announce"},
+			Parameter{"tr", 2, "udp://tracker3.example:6969"},
+			Parameter{"tr", 3, "udp://tracker3.example:6969"},
+			Parameter{"ws", 0, "http://seed.example/file"},
+		}},
+		ExpectedAdded:   []string{"udp://tracker3.example:6969"},
+		ExpectedRemoved: []string{"http://tracker1.example/announce"},
+	},
+}
+
+func TestIsSupersetOf(t *testing.T) {
+	scenarios := isSupersetOfScenarios
+	for _, scenario := range scenarios {
+		first := MagnetURI{Parameters: scenario.FirstParameters}
+		second := MagnetURI{Parameters: scenario.SecondParameters}
+		if result := first.IsSupersetOf(second); result !=
+			scenario.ExpectedFirstIsSuperset {
+			t.Errorf("Error on test %q: first is superset returns %t.",
+				scenario.Name, result)
+		}
+		if result := second.IsSupersetOf(first); result !=
+			scenario.ExpectedSecondIsSuperset {
+			t.Errorf("Error on test %q: second is superset returns %t.",
+				scenario.Name, result)
+		}
+	}
+}
+
+type isSupersetOfScenario struct {
+	compareParametersScenario
+	ExpectedFirstIsSuperset  bool
+	ExpectedSecondIsSuperset bool
+}
+
+var isSupersetOfScenarios = []isSupersetOfScenario{
+	{
+		// Empty parameters.
+		compareParametersScenario: compareParametersScenarios[0],
+		ExpectedFirstIsSuperset:   true,
+		ExpectedSecondIsSuperset:  true,
+	},
+	{
+		// Missing parameter.
+		compareParametersScenario: compareParametersScenarios[3],
+		ExpectedFirstIsSuperset:   true,
+		ExpectedSecondIsSuperset:  false,
+	},
+	{
+		// Extra parameter.
+		compareParametersScenario: compareParametersScenarios[4],
+		ExpectedFirstIsSuperset:   false,
+		ExpectedSecondIsSuperset:  true,
+	},
+	{
+		// Wrong index.
+		compareParametersScenario: compareParametersScenarios[6],
+		ExpectedFirstIsSuperset:   false,
+		ExpectedSecondIsSuperset:  false,
+	},
+}
+
 func TestCompareMagnetURIs(t *testing.T) {
 	scenarios := compareMagnetURIsScenarios
 	for _, scenario := range scenarios {
@@ -144,6 +319,56 @@ func TestCompareMagnetURIs(t *testing.T) {
 	}
 }
 
+func TestEqualPtr(t *testing.T) {
+	scenarios := compareMagnetURIsScenarios
+	for _, scenario := range scenarios {
+		result := scenario.FirstMagnetURI.EqualPtr(&scenario.SecondMagnetURI)
+		if result != scenario.ExpectedResult {
+			t.Errorf(
+				"Error on test %q: comparing %v and %v returns %t.",
+				scenario.Name, scenario.FirstMagnetURI,
+				scenario.SecondMagnetURI, result)
+		}
+	}
+}
+
+func TestEqualString(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		result, error := scenario.URIStruct.EqualString(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !result {
+			t.Errorf("Error on test %q: %v is not equal to %q.",
+				scenario.Name, scenario.URIStruct, scenario.RawMagnetURI)
+		}
+	}
+	magnetURI := magnetURIConvertionScenarios[5].URIStruct
+	result, error := magnetURI.EqualString("magnet:?xt=urn:btih:other")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if result {
+		t.Errorf("Error: %v is equal to a different Magnet URI.", magnetURI)
+	}
+	if _, error := magnetURI.EqualString("magnet:?unknown=value"); error == nil {
+		t.Error("No error was returned for a wrong raw Magnet URI.")
+	}
+}
+
+func TestEqualPtrNil(t *testing.T) {
+	var nilMagnetURI *MagnetURI
+	if !nilMagnetURI.EqualPtr(&MagnetURI{}) {
+		t.Errorf("Error: a nil Magnet URI is not equal to an empty one.")
+	}
+	magnetURI := &MagnetURI{[]Parameter{Parameter{"xt", 0, "xt1"}}}
+	if magnetURI.EqualPtr(nil) {
+		t.Errorf("Error: %v is equal to a nil Magnet URI.", magnetURI)
+	}
+}
+
 type compareMagnetURIsScenario struct {
 	Name            string
 	FirstMagnetURI  MagnetURI
@@ -252,168 +477,2120 @@ var compareMagnetURIsScenarios = []compareMagnetURIsScenario{
 	},
 }
 
-func TestParseMagnetURIWithErrors(t *testing.T) {
-	scenarios := parseMagnetURIWithErrorsScenarios
-	for _, scenario := range scenarios {
-		magnetURI, error := Parse(scenario.RawMagnetURI)
-		if !magnetURI.Equal(MagnetURI{}) {
-			t.Errorf(
-				"Error on test %q: a non-empty Magnet URI was returned: %v.",
-				scenario.Name, magnetURI)
+func TestRenumber(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker0.example/announce"},
+			Parameter{"tr", 5, "http://tracker5.example/announce"},
+			Parameter{"dn", 3, "name"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+		},
+	}
+	expectedParameters := []Parameter{
+		Parameter{"xt", 0, "urn:btih:abc"},
+		Parameter{"tr", 1, "http://tracker0.example/announce"},
+		Parameter{"tr", 2, "http://tracker5.example/announce"},
+		Parameter{"dn", 0, "name"},
+		Parameter{"tr", 3, "http://tracker2.example/announce"},
+	}
+	if renumbered := magnetURI.Renumber(); renumbered != 4 {
+		t.Errorf("Expected 4 renumbered parameters; got %d", renumbered)
+	}
+	if !reflect.DeepEqual(magnetURI.Parameters, expectedParameters) {
+		t.Errorf("Expected parameters: %v; got %v",
+			expectedParameters, magnetURI.Parameters)
+	}
+	if renumbered := magnetURI.Renumber(); renumbered != 0 {
+		t.Errorf("Expected 0 renumbered parameters; got %d", renumbered)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+			Parameter{"tr", 1, "http://tracker.example/announce"},
+			Parameter{"tr", 0, "http://other.example/announce"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		},
+	}
+	expectedParameters := []Parameter{
+		Parameter{"xt", 0, "urn:btih:abc"},
+		Parameter{"tr", 0, "http://tracker.example/announce"},
+		Parameter{"tr", 1, "http://tracker.example/announce"},
+		Parameter{"tr", 0, "http://other.example/announce"},
+	}
+	magnetURI.Dedup()
+	if len(magnetURI.Parameters) != len(expectedParameters) {
+		t.Fatalf("Expected parameters: %v; got %v",
+			expectedParameters, magnetURI.Parameters)
+	}
+	for i, parameter := range magnetURI.Parameters {
+		if parameter != expectedParameters[i] {
+			t.Errorf("Expected parameter %v; got %v",
+				expectedParameters[i], parameter)
 		}
-		if error == nil {
-			t.Error("No error was returned on %q test.", scenario.Name)
+	}
+}
+
+func TestGet(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[3].URIStruct
+	scenarios := getScenarios
+	for _, scenario := range scenarios {
+		value, present := magnetURI.Get(scenario.Prefix, scenario.Index)
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
 		}
-		if error.Error() != scenario.ExpectedError {
-			t.Errorf(
-				"Error on test %q: Expected error message: %q; got %q",
-				scenario.Name, scenario.ExpectedError, error.Error())
+		if value != scenario.ExpectedValue {
+			t.Errorf("Error on test %q: expected value %q; got %q",
+				scenario.Name, scenario.ExpectedValue, value)
 		}
 	}
 }
 
-type parseMagnetURIWithErrorsScenario struct {
-	Name          string
-	RawMagnetURI  string
-	ExpectedError string
+type getScenario struct {
+	Name            string
+	Prefix          Prefix
+	Index           int
+	ExpectedValue   string
+	ExpectedPresent bool
 }
 
-var parseMagnetURIWithErrorsScenarios = []parseMagnetURIWithErrorsScenario{
+var getScenarios = []getScenario{
 	{
-		Name:         "URI without magnet schema prefix",
-		RawMagnetURI: "I don't start with the magnet schema prefix.",
-		ExpectedError: "The string doesn't start with the Magnet URI schema " +
-			"prefix \"magnet:?\"",
+		Name:            "Indexed parameter",
+		Prefix:          "xt",
+		Index:           2,
+		ExpectedValue:   "urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
+		ExpectedPresent: true,
 	},
 	{
-		Name:          "URI without parameter prefix",
-		RawMagnetURI:  "magnet:?parameterwithoutprefix",
-		ExpectedError: "Parameter without prefix: \"parameterwithoutprefix\"",
+		Name:            "Missing index",
+		Prefix:          "xt",
+		Index:           0,
+		ExpectedValue:   "",
+		ExpectedPresent: false,
 	},
 	{
-		Name:          "URI with unknown parameter prefix",
-		RawMagnetURI:  "magnet:?unknown=value",
-		ExpectedError: "Unknown parameter prefix: \"unknown\"",
+		Name:            "Missing prefix",
+		Prefix:          "dn",
+		Index:           1,
+		ExpectedValue:   "",
+		ExpectedPresent: false,
 	},
 }
 
-func TestParseMagnetURI(t *testing.T) {
-	scenarios := magnetURIConvertionScenarios
+func TestGetWithoutIndex(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[1].URIStruct
+	value, present := magnetURI.Get("xt", 0)
+	if !present {
+		t.Error("The parameter without index was not found.")
+	}
+	if value != "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C" {
+		t.Errorf("Expected value %q; got %q",
+			"urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C", value)
+	}
+}
+
+func TestAddParameter(t *testing.T) {
+	scenarios := addParameterScenarios
 	for _, scenario := range scenarios {
-		magnetURI, error := Parse(scenario.RawMagnetURI)
+		magnetURI := MagnetURI{[]Parameter{{"xt", 0, "urn:btih:abc"}}}
+		error := magnetURI.AddParameter(scenario.Parameter)
+		errorMessage := ""
 		if error != nil {
-			t.Errorf("There was an error on test %q: %q",
-				scenario.Name, error.Error())
+			errorMessage = error.Error()
 		}
-		if !magnetURI.Equal(scenario.URIStruct) {
-			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
-				scenario.Name, scenario.URIStruct, magnetURI)
+		if errorMessage != scenario.ExpectedErrorMessage {
+			t.Errorf("Error on test %q: expected error %q; got %q",
+				scenario.Name, scenario.ExpectedErrorMessage, errorMessage)
+		}
+		expectedLen := 2
+		if error != nil {
+			expectedLen = 1
+		}
+		if magnetURI.Len() != expectedLen {
+			t.Errorf("Error on test %q: expected %d parameters; got %v",
+				scenario.Name, expectedLen, magnetURI.Parameters)
 		}
 	}
 }
 
-type magnetURIConvertionScenario struct {
-	Name         string
-	URIStruct    MagnetURI
-	RawMagnetURI string
+type addParameterScenario struct {
+	Name                 string
+	Parameter            Parameter
+	ExpectedErrorMessage string
 }
 
-var magnetURIConvertionScenarios = []magnetURIConvertionScenario{
-	// Overview examples taken from
-	// http://magnet-uri.sourceforge.net/magnet-draft-overview.txt
+var addParameterScenarios = []addParameterScenario{
 	{
-		Name: "Overview example 1",
-		URIStruct: MagnetURI{
+		Name:      "Valid parameter",
+		Parameter: Parameter{"tr", 1, "http://tracker.example/announce"},
+	},
+	{
+		Name:                 "Unknown prefix",
+		Parameter:            Parameter{"unknown", 0, "value"},
+		ExpectedErrorMessage: "Unknown parameter prefix: \"unknown\"",
+	},
+	{
+		Name:                 "Uppercase prefix",
+		Parameter:            Parameter{"DN", 0, "name"},
+		ExpectedErrorMessage: "Unknown parameter prefix: \"DN\"",
+	},
+	{
+		Name:                 "Negative index",
+		Parameter:            Parameter{"dn", -1, "name"},
+		ExpectedErrorMessage: "Wrong index for parameter \"dn\": -1",
+	},
+	{
+		Name:      "Wrong exact length",
+		Parameter: Parameter{"xl", 0, "ten"},
+		ExpectedErrorMessage: "Wrong exact length: \"ten\"; " +
+			"strconv.ParseInt: parsing \"ten\": invalid syntax",
+	},
+}
+
+func TestRemoveByPrefix(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:abc&tr.1=http://tracker1.example/announce&" +
+		"dn=name&tr.2=http://tracker2.example/announce")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	if removed := magnetURI.RemoveByPrefix("tr"); removed != 2 {
+		t.Errorf("Expected 2 parameters removed; got %d", removed)
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	if removed := magnetURI.RemoveByPrefix("tr"); removed != 0 {
+		t.Errorf("Expected 0 parameters removed; got %d", removed)
+	}
+}
+
+func TestRemoveByIndex(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:abc&tr.1=http://tracker1.example/announce&" +
+		"tr.2=http://tracker2.example/announce")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+		},
+	}
+	if !magnetURI.RemoveByIndex("tr", 1) {
+		t.Error("No parameter was removed.")
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	if magnetURI.RemoveByIndex("tr", 3) {
+		t.Error("A missing parameter was removed.")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	first := magnetURIConvertionScenarios[0].URIStruct
+	second := magnetURIConvertionScenarios[1].URIStruct
+	merged := first.Merge(second)
+	if !merged.Equal(second) {
+		t.Errorf("Expected Magnet URI: %v; got %v", second, merged)
+	}
+	if len(first.Parameters) != 1 {
+		t.Errorf("The first Magnet URI was modified: %v", first)
+	}
+}
+
+func TestMergeWithConflictingExactTopics(t *testing.T) {
+	first := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		},
+	}
+	second := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:def"},
+			Parameter{"ws", 0, "http://seed.example/file"},
+		},
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+			Parameter{"xt", 0, "urn:btih:def"},
+			Parameter{"ws", 0, "http://seed.example/file"},
+		},
+	}
+	merged := first.Merge(second)
+	if !merged.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v", expectedMagnetURI, merged)
+	}
+}
+
+func TestEqualContent(t *testing.T) {
+	scenarios := equalContentScenarios
+	for _, scenario := range scenarios {
+		result := scenario.FirstMagnetURI.EqualContent(scenario.SecondMagnetURI)
+		if result != scenario.ExpectedResult {
+			t.Errorf(
+				"Error on test %q: comparing %v and %v returns %t.",
+				scenario.Name, scenario.FirstMagnetURI,
+				scenario.SecondMagnetURI, result)
+		}
+	}
+}
+
+var equalContentScenarios = []compareMagnetURIsScenario{
+	{
+		Name: "Different trackers and display names",
+		FirstMagnetURI: MagnetURI{
 			Parameters: []Parameter{
-				Parameter{
-					"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
-				},
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"dn", 0, "first"},
+				Parameter{"tr", 0, "http://tracker1.example/announce"},
 			},
 		},
-		RawMagnetURI: "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
-	},
-	{
-		Name: "Overview example 2",
-		URIStruct: MagnetURI{
+		SecondMagnetURI: MagnetURI{
 			Parameters: []Parameter{
-				Parameter{
-					"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
-				},
-				Parameter{
-					"dn", 0, "Great+Speeches+-+Martin+Luther+King+Jr.+-+" +
-						"I+Have+A+Dream.mp3",
-				},
+				Parameter{"tr", 1, "http://tracker2.example/announce"},
+				Parameter{"tr", 2, "http://tracker3.example/announce"},
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"kt", 0, "keyword"},
 			},
 		},
-		RawMagnetURI: "magnet:?" +
-			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
-			"dn=Great+Speeches+-+Martin+Luther+King+Jr.+-+" +
-			"I+Have+A+Dream.mp3",
+		ExpectedResult: true,
 	},
 	{
-		Name: "Overview example 3",
-		URIStruct: MagnetURI{
+		Name: "Exact topics in different order",
+		FirstMagnetURI: MagnetURI{
 			Parameters: []Parameter{
-				Parameter{"kt", 0, "martin+luther+king+mp3"},
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"xt", 0, "urn:sha1:def"},
 			},
 		},
-		RawMagnetURI: "magnet:?kt=martin+luther+king+mp3",
-	},
-	{
-		Name: "Overview example 4",
-		URIStruct: MagnetURI{
+		SecondMagnetURI: MagnetURI{
 			Parameters: []Parameter{
-				Parameter{
-					"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
-				},
-				Parameter{
-					"xt", 2, "urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
-				},
+				Parameter{"xt", 0, "urn:sha1:def"},
+				Parameter{"xt", 0, "urn:btih:abc"},
 			},
 		},
-		RawMagnetURI: "magnet:?" +
-			"xt.1=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
-			"xt.2=urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
+		ExpectedResult: true,
 	},
 	{
-		Name: "Overview example 5",
-		URIStruct: MagnetURI{
+		Name: "Different exact topics",
+		FirstMagnetURI: MagnetURI{
 			Parameters: []Parameter{
-				Parameter{"mt", 0, "http://weblog.foo/all-my-favorites.rss"},
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		SecondMagnetURI: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:def"},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Missing exact topic",
+		FirstMagnetURI: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"xt", 0, "urn:sha1:def"},
+			},
+		},
+		SecondMagnetURI: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+			},
+		},
+		ExpectedResult: false,
+	},
+}
+
+func TestEqualIgnoringIndex(t *testing.T) {
+	scenarios := equalIgnoringIndexScenarios
+	for _, scenario := range scenarios {
+		result := scenario.FirstMagnetURI.EqualIgnoringIndex(
+			scenario.SecondMagnetURI)
+		if result != scenario.ExpectedResult {
+			t.Errorf(
+				"Error on test %q: comparing %v and %v returns %t.",
+				scenario.Name, scenario.FirstMagnetURI,
+				scenario.SecondMagnetURI, result)
+		}
+	}
+}
+
+var equalIgnoringIndexScenarios = []compareMagnetURIsScenario{
+	{
+		Name: "Different indices",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 1, "urn:btih:abc"},
+			{"xt", 2, "urn:btih:def"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:def"},
+			{"xt", 0, "urn:btih:abc"},
+		}},
+		ExpectedResult: true,
+	},
+	{
+		Name: "Different number of repeated values",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 1, "urn:btih:abc"},
+			{"xt", 2, "urn:btih:abc"},
+			{"dn", 0, "name"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"dn", 0, "name"},
+			{"dn", 0, "name"},
+		}},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Different prefixes",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			{"xs", 0, "http://example.org/file"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			{"as", 0, "http://example.org/file"},
+		}},
+		ExpectedResult: false,
+	},
+}
+
+func TestParseMagnetURIWithErrors(t *testing.T) {
+	scenarios := parseMagnetURIWithErrorsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if !magnetURI.Equal(MagnetURI{}) {
+			t.Errorf(
+				"Error on test %q: a non-empty Magnet URI was returned: %v.",
+				scenario.Name, magnetURI)
+		}
+		if error == nil {
+			t.Errorf("No error was returned on %q test.", scenario.Name)
+		}
+		if error.Error() != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, error.Error())
+		}
+	}
+}
+
+type parseMagnetURIWithErrorsScenario struct {
+	Name          string
+	RawMagnetURI  string
+	ExpectedError string
+}
+
+var parseMagnetURIWithErrorsScenarios = []parseMagnetURIWithErrorsScenario{
+	{
+		Name:         "URI without magnet schema prefix",
+		RawMagnetURI: "I don't start with the magnet schema prefix.",
+		ExpectedError: "The string doesn't start with the Magnet URI schema " +
+			"prefix \"magnet:?\"",
+	},
+	{
+		Name:          "URI without parameter prefix",
+		RawMagnetURI:  "magnet:?parameterwithoutprefix",
+		ExpectedError: "Parameter without prefix: \"parameterwithoutprefix\"",
+	},
+	{
+		Name:          "URI with unknown parameter prefix",
+		RawMagnetURI:  "magnet:?unknown=value",
+		ExpectedError: "Unknown parameter prefix: \"unknown\"",
+	},
+	{
+		Name: "URI with unknown parameter prefix in the middle",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"unknown=value&" +
+			"dn=name",
+		ExpectedError: "Unknown parameter prefix: \"unknown\"",
+	},
+	{
+		Name: "URI without parameter prefix in the middle",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"parameterwithoutprefix&" +
+			"kt=keyword",
+		ExpectedError: "Parameter without prefix: \"parameterwithoutprefix\"",
+	},
+	{
+		Name:          "URI with empty index",
+		RawMagnetURI:  "magnet:?xt.=urn:btih:abc",
+		ExpectedError: "Wrong parameter prefix: \"xt.\"; The index is empty",
+	},
+	{
+		Name:         "URI with negative index",
+		RawMagnetURI: "magnet:?xt.-1=urn:btih:abc",
+		ExpectedError: "Wrong parameter prefix: \"xt.-1\"; " +
+			"The index is negative",
+	},
+	{
+		Name:          "URI with zero index",
+		RawMagnetURI:  "magnet:?xt.0=urn:btih:abc",
+		ExpectedError: "Wrong parameter prefix: \"xt.0\"; The index is zero",
+	},
+	{
+		Name:         "URI with non-numeric index",
+		RawMagnetURI: "magnet:?xt.+1=urn:btih:abc",
+		ExpectedError: "Wrong parameter prefix: \"xt.+1\"; " +
+			"strconv.ParseUint: parsing \"+1\": invalid syntax",
+	},
+	{
+		Name:         "URI with overflowing index",
+		RawMagnetURI: "magnet:?xt.99999999999999999999=urn:btih:abc",
+		ExpectedError: "Wrong parameter prefix: \"xt.99999999999999999999\"; " +
+			"strconv.ParseUint: parsing \"99999999999999999999\": " +
+			"value out of range",
+	},
+	{
+		Name:         "URI with index out of range",
+		RawMagnetURI: "magnet:?x.pe.2147483648=1.2.3.4:6881",
+		ExpectedError: "Wrong parameter prefix: \"x.pe.2147483648\"; " +
+			"strconv.ParseUint: parsing \"2147483648\": value out of range",
+	},
+	{
+		Name:         "URI with DHT node without port",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&dht=router.example",
+		ExpectedError: "Wrong DHT node: \"router.example\"; " +
+			"address router.example: missing port in address",
+	},
+	{
+		Name:         "URI with DHT node with wrong port",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&x.dht=router.example:http",
+		ExpectedError: "Wrong DHT node: \"router.example:http\"; " +
+			"Wrong port: \"http\"",
+	},
+	{
+		Name:          "URI with DHT node without host",
+		RawMagnetURI:  "magnet:?xt=urn:btih:abc&dht=:6881",
+		ExpectedError: "Wrong DHT node: \":6881\"; The host is empty",
+	},
+	{
+		Name:         "URI with non-numeric exact length",
+		RawMagnetURI: "magnet:?xl=ten",
+		ExpectedError: "Wrong exact length: \"ten\"; " +
+			"strconv.ParseInt: parsing \"ten\": invalid syntax",
+	},
+	{
+		Name:          "URI with negative exact length",
+		RawMagnetURI:  "magnet:?xl=-1",
+		ExpectedError: "Wrong exact length: \"-1\"; The exact length is negative",
+	},
+}
+
+func TestParseMagnetURI(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+type magnetURIConvertionScenario struct {
+	Name         string
+	URIStruct    MagnetURI
+	RawMagnetURI string
+}
+
+var magnetURIConvertionScenarios = []magnetURIConvertionScenario{
+	// Overview examples taken from
+	// http://magnet-uri.sourceforge.net/magnet-draft-overview.txt
+	{
+		Name: "Overview example 1",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+				},
+			},
+		},
+		RawMagnetURI: "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+	},
+	{
+		Name: "Overview example 2",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+				},
+				Parameter{
+					"dn", 0, "Great+Speeches+-+Martin+Luther+King+Jr.+-+" +
+						"I+Have+A+Dream.mp3",
+				},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"dn=Great+Speeches+-+Martin+Luther+King+Jr.+-+" +
+			"I+Have+A+Dream.mp3",
+	},
+	{
+		Name: "Overview example 3",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"kt", 0, "martin+luther+king+mp3"},
+			},
+		},
+		RawMagnetURI: "magnet:?kt=martin+luther+king+mp3",
+	},
+	{
+		Name: "Overview example 4",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+				},
+				Parameter{
+					"xt", 2, "urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
+				},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"xt.2=urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
+	},
+	{
+		Name: "Overview example 5",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"mt", 0, "http://weblog.foo/all-my-favorites.rss"},
 			},
 		},
 		RawMagnetURI: "magnet:?mt=http://weblog.foo/all-my-favorites.rss",
 	},
+	{
+		Name: "Address tracker",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				},
+				Parameter{"tr", 0, "http://tracker.example/announce"},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"tr=http://tracker.example/announce",
+	},
+	{
+		Name: "Indexed address trackers",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				},
+				Parameter{"tr", 1, "http://tracker1.example/announce"},
+				Parameter{"tr", 2, "udp://tracker2.example:6969"},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"tr.1=http://tracker1.example/announce&" +
+			"tr.2=udp://tracker2.example:6969",
+	},
+	{
+		Name: "Exact length",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{
+					"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+				},
+				Parameter{"xl", 0, "010485760"},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"xl=010485760",
+	},
+	{
+		Name: "Web seed with query",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"ws", 0, "https://seed.example/path?foo=bar"},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:abc&ws=https://seed.example/path?foo=bar",
+	},
+	{
+		Name: "Exact and acceptable sources",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+				Parameter{
+					"xs", 0, "http://cache.example/uri-res/N2R?" +
+						"urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+				},
+				Parameter{
+					"as", 0, "http://download.example/get?id=42%26x=y",
+				},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"xs=http://cache.example/uri-res/N2R?" +
+			"urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+			"as=http://download.example/get?id=42%26x=y",
+	},
+	{
+		Name: "Web seed with unencoded query separators",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{
+					"ws", 0, "https://seed.example/file?id=1&token=x&raw",
+				},
+				Parameter{"dn", 0, "name"},
+			},
+		},
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&" +
+			"ws=https://seed.example/file?id=1&token=x&raw&dn=name",
+	},
+	{
+		Name: "Peer address",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"x.pe", 0, "1.2.3.4:6881"},
+			},
+		},
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&x.pe=1.2.3.4:6881",
+	},
+	{
+		Name: "Indexed peer addresses",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"x.pe", 1, "1.2.3.4:6881"},
+				Parameter{"x.pe", 2, "[2001:db8::1]:6881"},
+			},
+		},
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:abc&x.pe.1=1.2.3.4:6881&x.pe.2=[2001:db8::1]:6881",
+	},
+	{
+		Name: "Select only",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"so", 0, "0,2,4-6"},
+			},
+		},
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&so=0,2,4-6",
+	},
+}
+
+func TestCanonical(t *testing.T) {
+	first := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+			Parameter{"dn", 0, "name"},
+			Parameter{"xl", 0, "10485760"},
+			Parameter{"tr", 1, "http://tracker1.example/announce"},
+			Parameter{"xt", 0, "urn:btih:abc"},
+		},
+	}
+	second := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 1, "http://tracker1.example/announce"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+			Parameter{"xl", 0, "10485760"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	expectedCanonical := "magnet:?xt=urn:btih:abc&xl=10485760&dn=name&" +
+		"tr.1=http://tracker1.example/announce&" +
+		"tr.2=http://tracker2.example/announce"
+	for _, magnetURI := range []MagnetURI{first, second} {
+		canonical, error := magnetURI.Canonical()
+		if error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		if canonical != expectedCanonical {
+			t.Errorf("Expected canonical Magnet URI: %q; got %q",
+				expectedCanonical, canonical)
+		}
+	}
+	firstString, _ := first.String()
+	if firstString == expectedCanonical {
+		t.Error("String doesn't keep the insertion order.")
+	}
+}
+
+func TestSort(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"x.custom", 0, "value"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+			Parameter{"dn", 0, "name"},
+			Parameter{"tr", 1, "http://tracker1.example/announce"},
+			Parameter{"xt", 0, "urn:btih:abc"},
+		},
+	}
+	expectedMagnetURI := "magnet:?xt=urn:btih:abc&dn=name&" +
+		"tr.1=http://tracker1.example/announce&" +
+		"tr.2=http://tracker2.example/announce&x.custom=value"
+	for i := 0; i < 2; i++ {
+		magnetURI.Sort()
+		magnetURIString, error := magnetURI.String()
+		if error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		if magnetURIString != expectedMagnetURI {
+			t.Errorf("Expected sorted Magnet URI: %q; got %q",
+				expectedMagnetURI, magnetURIString)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	first := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"dn", 0, "name"},
+			Parameter{"xt", 0, "urn:btih:abc"},
+		},
+	}
+	second := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	third := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		},
+	}
+	expectedFingerprint := "9f879871ea188fda36e6ff46a8575728" +
+		"6d74805a6813335eb1d75da945a6760e"
+	if first.Fingerprint() != expectedFingerprint {
+		t.Errorf("Expected fingerprint %q; got %q",
+			expectedFingerprint, first.Fingerprint())
+	}
+	if first.Fingerprint() != second.Fingerprint() {
+		t.Error("Equal Magnet URIs have different fingerprints.")
+	}
+	if first.Fingerprint() == third.Fingerprint() {
+		t.Error("Different Magnet URIs have the same fingerprint.")
+	}
+	if first.ContentFingerprint() != third.ContentFingerprint() {
+		t.Error("Magnet URIs with the same content have different " +
+			"content fingerprints.")
+	}
+	if first.ContentFingerprint() == first.Fingerprint() {
+		t.Error("The content fingerprint includes the display name.")
+	}
+}
+
+func TestFormatMagnetURI(t *testing.T) {
+	scenarios := formatMagnetURIScenarios
+	for _, scenario := range scenarios {
+		result := fmt.Sprintf(scenario.Format, scenario.URIStruct)
+		if result != scenario.ExpectedResult {
+			t.Errorf("Error on test %q: expected %q; got %q",
+				scenario.Name, scenario.ExpectedResult, result)
+		}
+	}
+}
+
+type formatMagnetURIScenario struct {
+	Name           string
+	Format         string
+	URIStruct      MagnetURI
+	ExpectedResult string
+}
+
+var formatMagnetURIScenarios = []formatMagnetURIScenario{
+	{
+		Name:   "Value",
+		Format: "%v",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"kt", 0, "king+mp3"}},
+		},
+		ExpectedResult: "magnet:?kt=king+mp3",
+	},
+	{
+		Name:   "String",
+		Format: "%s",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"kt", 0, "king+mp3"}},
+		},
+		ExpectedResult: "magnet:?kt=king+mp3",
+	},
+	{
+		Name:   "Quoted",
+		Format: "%q",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"kt", 0, "king+mp3"}},
+		},
+		ExpectedResult: "\"magnet:?kt=king+mp3\"",
+	},
+	{
+		Name:   "Go syntax",
+		Format: "%#v",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"kt", 0, "king+mp3"}},
+		},
+		ExpectedResult: "magneturi.MagnetURI{Parameters:[]magneturi.Parameter{" +
+			"magneturi.Parameter{Prefix:\"kt\", Index:0, Value:\"king+mp3\"}}}",
+	},
+	{
+		Name:           "No parameters",
+		Format:         "%v",
+		URIStruct:      MagnetURI{},
+		ExpectedResult: "<invalid magnet uri>",
+	},
+}
+
+func TestMagnetURIToStringWithoutParameters(t *testing.T) {
+	magnetURI := MagnetURI{}
+	magnetURIString, error := magnetURI.String()
+	expectedErrorMessage := "The Magnet URI has no parameters."
+	if magnetURIString != "" {
+		t.Errorf("A Magnet URI string was returned: %q.", magnetURIString)
+	}
+	if error == nil {
+		t.Error("No error was returned.")
+	}
+	if error.Error() != expectedErrorMessage {
+		t.Errorf(
+			"Expected error message: %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+}
+
+func TestIsEmpty(t *testing.T) {
+	magnetURI := MagnetURI{}
+	if !magnetURI.IsEmpty() {
+		t.Error("The Magnet URI without parameters is not empty.")
+	}
+	magnetURI = MagnetURI{[]Parameter{{"tr", 0, "http://tracker"}}}
+	if magnetURI.IsEmpty() {
+		t.Error("The Magnet URI with only a tracker is empty.")
+	}
+	if _, error := magnetURI.String(); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+}
+
+func TestMagnetURIToString(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		magnetURIString, error := scenario.URIStruct.String()
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if magnetURIString != scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected Magnet URI: %q; got %q",
+				scenario.Name, scenario.RawMagnetURI, magnetURIString)
+		}
+	}
+}
+
+func TestSerializedLen(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		serializedLen := scenario.URIStruct.SerializedLen()
+		if serializedLen != len(scenario.RawMagnetURI) {
+			t.Errorf("Error on test %q: expected length %d; got %d",
+				scenario.Name, len(scenario.RawMagnetURI), serializedLen)
+		}
+	}
+	magnetURI := MagnetURI{}
+	if serializedLen := magnetURI.SerializedLen(); serializedLen != 0 {
+		t.Errorf("Expected length 0; got %d", serializedLen)
+	}
+}
+
+func TestMustString(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		magnetURIString := scenario.URIStruct.MustString()
+		if magnetURIString != scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected Magnet URI: %q; got %q",
+				scenario.Name, scenario.RawMagnetURI, magnetURIString)
+		}
+	}
+	magnetURI := MagnetURI{}
+	if magnetURIString := magnetURI.MustString(); magnetURIString != "" {
+		t.Errorf("A Magnet URI string was returned: %q.", magnetURIString)
+	}
+}
+
+func TestAppendTo(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		buffer := []byte("links: ")
+		buffer, error := scenario.URIStruct.AppendTo(buffer)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if string(buffer) != "links: "+scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected buffer %q; got %q",
+				scenario.Name, "links: "+scenario.RawMagnetURI, buffer)
+		}
+	}
+	magnetURI := MagnetURI{}
+	buffer, error := magnetURI.AppendTo([]byte("links: "))
+	if error == nil {
+		t.Error("No error was returned.")
+	}
+	if string(buffer) != "links: " {
+		t.Errorf("The buffer was changed: %q", buffer)
+	}
+}
+
+func BenchmarkAppendTo(b *testing.B) {
+	magnetURI := magnetURIConvertionScenarios[5].URIStruct
+	buffer := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		buffer, _ = magnetURI.AppendTo(buffer[:0])
+	}
+}
+
+func BenchmarkString(b *testing.B) {
+	magnetURI := magnetURIConvertionScenarios[5].URIStruct
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		magnetURI.String()
+	}
+}
+
+func BenchmarkStringWithEscapedValues(b *testing.B) {
+	magnetURI := MagnetURI{[]Parameter{
+		Parameter{"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+		Parameter{"dn", 0, "Great Speeches - I Have A Dream.mp3"},
+		Parameter{"tr", 0, "http://tracker.example/announce?key=a b"},
+	}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		magnetURI.String()
+	}
+}
+
+func TestLenAndCount(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[6].URIStruct
+	if magnetURI.Len() != 3 {
+		t.Errorf("Expected 3 parameters; got %d", magnetURI.Len())
+	}
+	scenarios := map[Prefix]int{"xt": 1, "tr": 2, "dn": 0}
+	for prefix, expectedCount := range scenarios {
+		if count := magnetURI.Count(prefix); count != expectedCount {
+			t.Errorf("Expected %d parameters with prefix %q; got %d",
+				expectedCount, prefix, count)
+		}
+	}
+	emptyMagnetURI := MagnetURI{}
+	if emptyMagnetURI.Len() != 0 {
+		t.Errorf("Expected 0 parameters; got %d", emptyMagnetURI.Len())
+	}
+}
+
+func TestRangeByPrefix(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[3].URIStruct
+	var values []string
+	magnetURI.RangeByPrefix("xt", func(parameter Parameter) bool {
+		values = append(values, parameter.Value)
+		return true
+	})
+	expectedValues := []string{
+		"urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		"urn:sha1:TXGCZQTH26NL6OUQAJJPFALHG2LTGBC7",
+	}
+	if fmt.Sprintf("%q", values) != fmt.Sprintf("%q", expectedValues) {
+		t.Errorf("Expected values %q; got %q", expectedValues, values)
+	}
+	calls := 0
+	magnetURI.RangeByPrefix("xt", func(parameter Parameter) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("Expected the range to stop after 1 call; got %d", calls)
+	}
+	allocations := testing.AllocsPerRun(100, func() {
+		magnetURI.RangeByPrefix("xt", func(parameter Parameter) bool {
+			return true
+		})
+	})
+	if allocations != 0 {
+		t.Errorf("Expected no allocations; got %v", allocations)
+	}
+}
+
+func TestPrimaryExactTopic(t *testing.T) {
+	scenarios := primaryExactTopicScenarios
+	for _, scenario := range scenarios {
+		exactTopic, present := scenario.URIStruct.PrimaryExactTopic()
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
+		}
+		if exactTopic != scenario.ExpectedExactTopic {
+			t.Errorf("Error on test %q: expected exact topic %v; got %v",
+				scenario.Name, scenario.ExpectedExactTopic, exactTopic)
+		}
+	}
+}
+
+type primaryExactTopicScenario struct {
+	Name               string
+	URIStruct          MagnetURI
+	ExpectedExactTopic Parameter
+	ExpectedPresent    bool
+}
+
+var primaryExactTopicScenarios = []primaryExactTopicScenario{
+	{
+		Name:      "Overview example 4",
+		URIStruct: magnetURIConvertionScenarios[3].URIStruct,
+		ExpectedExactTopic: Parameter{
+			"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		ExpectedPresent: true,
+	},
+	{
+		Name: "Lowest index not first",
+		URIStruct: MagnetURI{[]Parameter{
+			{"xt", 2, "urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1"},
+			{"dn", 0, "name"},
+			{"xt", 1, "urn:btih:abc"},
+		}},
+		ExpectedExactTopic: Parameter{"xt", 1, "urn:btih:abc"},
+		ExpectedPresent:    true,
+	},
+	{
+		Name: "Exact topic without index",
+		URIStruct: MagnetURI{[]Parameter{
+			{"xt", 1, "urn:btih:abc"},
+			{"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		}},
+		ExpectedExactTopic: Parameter{
+			"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		ExpectedPresent: true,
+	},
+	{
+		Name:      "No exact topic",
+		URIStruct: MagnetURI{[]Parameter{{"dn", 0, "name"}}},
+	},
+}
+
+func TestAll(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[3].URIStruct
+	var parameters []Parameter
+	for parameter := range magnetURI.All() {
+		parameters = append(parameters, parameter)
+	}
+	if !reflect.DeepEqual(parameters, magnetURI.Parameters) {
+		t.Errorf("Expected parameters: %v; got %v",
+			magnetURI.Parameters, parameters)
+	}
+	count := 0
+	for range magnetURI.All() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected to stop after 1 parameter; got %d", count)
+	}
+}
+
+func TestByPrefix(t *testing.T) {
+	magnetURI := magnetURIConvertionScenarios[3].URIStruct
+	var parameters []Parameter
+	for parameter := range magnetURI.ByPrefix("xt") {
+		parameters = append(parameters, parameter)
+	}
+	if !reflect.DeepEqual(parameters, magnetURI.ExactTopics()) {
+		t.Errorf("Expected parameters: %v; got %v",
+			magnetURI.ExactTopics(), parameters)
+	}
+	for range magnetURI.ByPrefix("xt") {
+		break
+	}
+}
+
+func TestTrackers(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+		"tr.1=http://tracker1.example/announce&" +
+		"dn=name&" +
+		"tr.2=udp://tracker2.example:6969")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedTrackers := []Parameter{
+		Parameter{"tr", 1, "http://tracker1.example/announce"},
+		Parameter{"tr", 2, "udp://tracker2.example:6969"},
+	}
+	trackers := magnetURI.Trackers()
+	if !compareParameters(trackers, expectedTrackers) {
+		t.Errorf("Expected trackers: %v; got %v", expectedTrackers, trackers)
+	}
+}
+
+func TestIsTrackerless(t *testing.T) {
+	scenarios := isTrackerlessScenarios
+	for _, scenario := range scenarios {
+		result := scenario.MagnetURI.IsTrackerless()
+		if result != scenario.ExpectedResult {
+			t.Errorf("Error on test %q: expected %t; got %t",
+				scenario.Name, scenario.ExpectedResult, result)
+		}
+	}
+}
+
+type isTrackerlessScenario struct {
+	Name           string
+	MagnetURI      MagnetURI
+	ExpectedResult bool
+}
+
+var isTrackerlessScenarios = []isTrackerlessScenario{
+	{
+		Name:           "Empty Magnet URI",
+		MagnetURI:      MagnetURI{},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Only exact topic",
+		MagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+		}},
+		ExpectedResult: true,
+	},
+	{
+		Name: "Exact topic and tracker",
+		MagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		}},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Exact topic and web seed",
+		MagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"ws", 0, "http://seed.example/file"},
+		}},
+		ExpectedResult: true,
+	},
+	{
+		Name: "Tracker without exact topic",
+		MagnetURI: MagnetURI{[]Parameter{
+			Parameter{"kt", 0, "martin"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		}},
+		ExpectedResult: false,
+	},
+}
+
+func TestAddTrackers(t *testing.T) {
+	scenarios := addTrackersScenarios
+	urls := []string{
+		"http://tracker1.example/announce",
+		"udp://tracker3.example:6969",
+		"",
+		"udp://tracker3.example:6969",
+	}
+	for _, scenario := range scenarios {
+		magnetURI := scenario.URIStruct.AddTrackers(urls)
+		if !reflect.DeepEqual(magnetURI, scenario.ExpectedMagnetURI) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.ExpectedMagnetURI, magnetURI)
+		}
+	}
+}
+
+type addTrackersScenario struct {
+	Name              string
+	URIStruct         MagnetURI
+	ExpectedMagnetURI MagnetURI
+}
+
+var addTrackersScenarios = []addTrackersScenario{
+	{
+		Name:      "Without trackers",
+		URIStruct: MagnetURI{[]Parameter{{"xt", 0, "urn:btih:abc"}}},
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"tr", 0, "http://tracker1.example/announce"},
+			{"tr", 0, "udp://tracker3.example:6969"},
+		}},
+	},
+	{
+		Name: "Indexed trackers",
+		URIStruct: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"tr", 1, "http://tracker1.example/announce"},
+			{"tr", 2, "udp://tracker2.example:6969"},
+		}},
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"tr", 1, "http://tracker1.example/announce"},
+			{"tr", 2, "udp://tracker2.example:6969"},
+			{"tr", 3, "udp://tracker3.example:6969"},
+		}},
+	},
+	{
+		Name: "Trackers without index",
+		URIStruct: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"tr", 0, "udp://tracker2.example:6969"},
+		}},
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"tr", 0, "udp://tracker2.example:6969"},
+			{"tr", 0, "http://tracker1.example/announce"},
+			{"tr", 0, "udp://tracker3.example:6969"},
+		}},
+	},
+}
+
+func TestWebSeeds(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:abc&" +
+		"ws=https://seed.example/path?foo=bar&" +
+		"ws=ftp://mirror.example/file")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedWebSeeds := []Parameter{
+		Parameter{"ws", 0, "https://seed.example/path?foo=bar"},
+		Parameter{"ws", 0, "ftp://mirror.example/file"},
+	}
+	webSeeds := magnetURI.WebSeeds()
+	if !compareParameters(webSeeds, expectedWebSeeds) {
+		t.Errorf("Expected web seeds: %v; got %v", expectedWebSeeds, webSeeds)
+	}
+}
+
+func TestSources(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+		"xs=http://cache.example/file?a=1&" +
+		"as=http://mirror1.example/file?b=2&" +
+		"as.1=http://mirror2.example/file")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedExactSources := []Parameter{
+		Parameter{"xs", 0, "http://cache.example/file?a=1"},
+	}
+	exactSources := magnetURI.ExactSources()
+	if !compareParameters(exactSources, expectedExactSources) {
+		t.Errorf("Expected exact sources: %v; got %v",
+			expectedExactSources, exactSources)
+	}
+	expectedAcceptableSources := []Parameter{
+		Parameter{"as", 0, "http://mirror1.example/file?b=2"},
+		Parameter{"as", 1, "http://mirror2.example/file"},
+	}
+	acceptableSources := magnetURI.AcceptableSources()
+	if !compareParameters(acceptableSources, expectedAcceptableSources) {
+		t.Errorf("Expected acceptable sources: %v; got %v",
+			expectedAcceptableSources, acceptableSources)
+	}
+}
+
+func TestPeers(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:abc&x.pe=1.2.3.4:6881&dn=name&x.pe=5.6.7.8:51413")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedPeers := []Parameter{
+		Parameter{"x.pe", 0, "1.2.3.4:6881"},
+		Parameter{"x.pe", 0, "5.6.7.8:51413"},
+	}
+	peers := magnetURI.Peers()
+	if !compareParameters(peers, expectedPeers) {
+		t.Errorf("Expected peers: %v; got %v", expectedPeers, peers)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	scenarios := displayNameScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		displayName, present := magnetURI.DisplayName()
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
+		}
+		if displayName != scenario.ExpectedDisplayName {
+			t.Errorf("Error on test %q: expected display name %q; got %q",
+				scenario.Name, scenario.ExpectedDisplayName, displayName)
+		}
+	}
+}
+
+type displayNameScenario struct {
+	Name                string
+	RawMagnetURI        string
+	ExpectedDisplayName string
+	ExpectedPresent     bool
+}
+
+var displayNameScenarios = []displayNameScenario{
+	{
+		Name:         "Plus signs",
+		RawMagnetURI: magnetURIConvertionScenarios[1].RawMagnetURI,
+		ExpectedDisplayName: "Great Speeches - Martin Luther King Jr. - " +
+			"I Have A Dream.mp3",
+		ExpectedPresent: true,
+	},
+	{
+		Name:                "Percent-encoded",
+		RawMagnetURI:        "magnet:?xt=urn:btih:abc&dn=A%20%26%20B",
+		ExpectedDisplayName: "A & B",
+		ExpectedPresent:     true,
+	},
+	{
+		Name:                "First of several",
+		RawMagnetURI:        "magnet:?dn.1=first&dn.2=second",
+		ExpectedDisplayName: "first",
+		ExpectedPresent:     true,
+	},
+	{
+		Name:                "Wrong percent-encoding",
+		RawMagnetURI:        "magnet:?xt=urn:btih:abc&dn=100%",
+		ExpectedDisplayName: "",
+		ExpectedPresent:     false,
+	},
+	{
+		Name:                "No display name",
+		RawMagnetURI:        "magnet:?xt=urn:btih:abc",
+		ExpectedDisplayName: "",
+		ExpectedPresent:     false,
+	},
+}
+
+func TestKeywords(t *testing.T) {
+	scenarios := keywordsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		keywords := magnetURI.Keywords()
+		if keywords == nil {
+			t.Errorf("Error on test %q: nil keywords", scenario.Name)
+		}
+		if fmt.Sprintf("%q", keywords) !=
+			fmt.Sprintf("%q", scenario.ExpectedKeywords) {
+			t.Errorf("Error on test %q: expected keywords %q; got %q",
+				scenario.Name, scenario.ExpectedKeywords, keywords)
+		}
+	}
+}
+
+type keywordsScenario struct {
+	Name             string
+	RawMagnetURI     string
+	ExpectedKeywords []string
+}
+
+var keywordsScenarios = []keywordsScenario{
+	{
+		Name:             "Overview example 3",
+		RawMagnetURI:     "magnet:?kt=martin+luther+king+mp3",
+		ExpectedKeywords: []string{"martin", "luther", "king", "mp3"},
+	},
+	{
+		Name:             "Empty terms",
+		RawMagnetURI:     "magnet:?kt=+martin++king+",
+		ExpectedKeywords: []string{"martin", "king"},
+	},
+	{
+		Name:             "Percent-encoded terms",
+		RawMagnetURI:     "magnet:?kt=caf%C3%A9+rock%26roll",
+		ExpectedKeywords: []string{"café", "rock&roll"},
+	},
+	{
+		Name:             "Several keyword topics",
+		RawMagnetURI:     "magnet:?kt.1=martin+king&kt.2=king+speech",
+		ExpectedKeywords: []string{"martin", "king", "speech"},
+	},
+	{
+		Name:             "No keyword topics",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc",
+		ExpectedKeywords: []string{},
+	},
 }
 
-func TestMagnetURIToStringWithoutParameters(t *testing.T) {
-	magnetURI := MagnetURI{}
-	magnetURIString, error := magnetURI.String()
-	expectedErrorMessage := "The Magnet URI has no parameters."
-	if magnetURIString != "" {
-		t.Errorf("A Magnet URI string was returned: %q.", magnetURIString)
+func TestDHTNodes(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt=urn:btih:abc&" +
+		"dht=router.example:6881&" +
+		"x.dht.1=1.2.3.4:6881&" +
+		"x.dht.2=[2001:db8::1]:51413")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
 	}
-	if error == nil {
-		t.Error("No error was returned.")
+	expectedNodes := []string{
+		"router.example:6881", "1.2.3.4:6881", "[2001:db8::1]:51413",
 	}
-	if error.Error() != expectedErrorMessage {
-		t.Errorf(
-			"Expected error message: %q; got %q",
-			expectedErrorMessage, error.Error())
+	nodes := magnetURI.DHTNodes()
+	if fmt.Sprintf("%q", nodes) != fmt.Sprintf("%q", expectedNodes) {
+		t.Errorf("Expected DHT nodes %q; got %q", expectedNodes, nodes)
+	}
+	expectedParameter := Parameter{"x.dht", 2, "[2001:db8::1]:51413"}
+	if magnetURI.Parameters[3] != expectedParameter {
+		t.Errorf("Expected parameter %v; got %v",
+			expectedParameter, magnetURI.Parameters[3])
 	}
 }
 
-func TestMagnetURIToString(t *testing.T) {
+func TestSuggestedFilename(t *testing.T) {
+	scenarios := suggestedFilenameScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		filename, present := magnetURI.SuggestedFilename()
+		if present != (scenario.ExpectedFilename != "") {
+			t.Errorf("Error on test %q: filename present %t",
+				scenario.Name, present)
+		}
+		if filename != scenario.ExpectedFilename {
+			t.Errorf("Error on test %q: expected filename %q; got %q",
+				scenario.Name, scenario.ExpectedFilename, filename)
+		}
+	}
+}
+
+type suggestedFilenameScenario struct {
+	Name             string
+	RawMagnetURI     string
+	ExpectedFilename string
+}
+
+var suggestedFilenameScenarios = []suggestedFilenameScenario{
+	{
+		Name:             "Plain name",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc&dn=I+Have+A+Dream.mp3",
+		ExpectedFilename: "I Have A Dream.mp3",
+	},
+	{
+		Name:             "Path separators",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc&dn=../../etc%2Fpasswd",
+		ExpectedFilename: ".._.._etc_passwd",
+	},
+	{
+		Name:             "Windows path separators",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc&dn=C:%5Cfile.txt",
+		ExpectedFilename: "C:_file.txt",
+	},
+	{
+		Name:             "Control characters",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc&dn=file%00name%0A.txt%7F",
+		ExpectedFilename: "filename.txt",
+	},
+	{
+		Name:             "Parent directory",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc&dn=..",
+		ExpectedFilename: "",
+	},
+	{
+		Name:             "No display name",
+		RawMagnetURI:     "magnet:?xt=urn:btih:abc",
+		ExpectedFilename: "",
+	},
+}
+
+func TestIsDirectoryName(t *testing.T) {
+	scenarios := isDirectoryNameScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		result := magnetURI.IsDirectoryName()
+		if result != scenario.ExpectedResult {
+			t.Errorf("Error on test %q: expected %t; got %t",
+				scenario.Name, scenario.ExpectedResult, result)
+		}
+	}
+}
+
+type isDirectoryNameScenario struct {
+	Name           string
+	RawMagnetURI   string
+	ExpectedResult bool
+}
+
+var isDirectoryNameScenarios = []isDirectoryNameScenario{
+	{
+		Name:           "Plain name",
+		RawMagnetURI:   "magnet:?xt=urn:btih:abc&dn=I+Have+A+Dream.mp3",
+		ExpectedResult: false,
+	},
+	{
+		Name:           "Path name",
+		RawMagnetURI:   "magnet:?xt=urn:btih:abc&dn=Speeches/I+Have+A+Dream.mp3",
+		ExpectedResult: true,
+	},
+	{
+		Name:           "Percent-encoded path name",
+		RawMagnetURI:   "magnet:?xt=urn:btih:abc&dn=Speeches%2FKing",
+		ExpectedResult: true,
+	},
+	{
+		Name:           "No display name",
+		RawMagnetURI:   "magnet:?xt=urn:btih:abc",
+		ExpectedResult: false,
+	},
+}
+
+func TestExactLength(t *testing.T) {
+	scenarios := exactLengthScenarios
+	for _, scenario := range scenarios {
+		exactLength, present := scenario.URIStruct.ExactLength()
+		if present != scenario.ExpectedPresent {
+			t.Errorf("Error on test %q: expected present %t; got %t",
+				scenario.Name, scenario.ExpectedPresent, present)
+		}
+		if exactLength != scenario.ExpectedExactLength {
+			t.Errorf("Error on test %q: expected exact length %d; got %d",
+				scenario.Name, scenario.ExpectedExactLength, exactLength)
+		}
+	}
+}
+
+type exactLengthScenario struct {
+	Name                string
+	URIStruct           MagnetURI
+	ExpectedExactLength int64
+	ExpectedPresent     bool
+}
+
+var exactLengthScenarios = []exactLengthScenario{
+	{
+		Name: "Exact length present",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"xl", 0, "10485760"}},
+		},
+		ExpectedExactLength: 10485760,
+		ExpectedPresent:     true,
+	},
+	{
+		Name: "Exact length missing",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{Parameter{"dn", 0, "name"}},
+		},
+		ExpectedExactLength: 0,
+		ExpectedPresent:     false,
+	},
+}
+
+func TestSelectedFileIndices(t *testing.T) {
+	scenarios := selectedFileIndicesScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		indices, error := magnetURI.SelectedFileIndices()
+		errorMessage := ""
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf("Error on test %q: expected error %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+		if fmt.Sprint(indices) != fmt.Sprint(scenario.ExpectedIndices) {
+			t.Errorf("Error on test %q: expected indices %v; got %v",
+				scenario.Name, scenario.ExpectedIndices, indices)
+		}
+	}
+}
+
+type selectedFileIndicesScenario struct {
+	Name            string
+	RawMagnetURI    string
+	ExpectedIndices []int
+	ExpectedError   string
+}
+
+var selectedFileIndicesScenarios = []selectedFileIndicesScenario{
+	{
+		Name:            "Indices and ranges",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=0,2,4-6",
+		ExpectedIndices: []int{0, 2, 4, 5, 6},
+	},
+	{
+		Name:            "Unsorted and overlapping",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=5,1-3,2",
+		ExpectedIndices: []int{1, 2, 3, 5},
+	},
+	{
+		Name:            "No select only",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc",
+		ExpectedIndices: []int{},
+	},
+	{
+		Name:            "Open range",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=4-",
+		ExpectedIndices: nil,
+		ExpectedError: "Wrong select only file index: \"4-\"; " +
+			"The file index is empty",
+	},
+	{
+		Name:            "Letters",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=a,b",
+		ExpectedIndices: nil,
+		ExpectedError: "Wrong select only file index: \"a\"; " +
+			"strconv.Atoi: parsing \"a\": invalid syntax",
+	},
+	{
+		Name:            "Reversed range",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=6-4",
+		ExpectedIndices: nil,
+		ExpectedError: "Wrong select only file index: \"6-4\"; " +
+			"The range end is lower than its start",
+	},
+	{
+		Name:            "Huge range",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=0-5000000",
+		ExpectedIndices: nil,
+		ExpectedError: "Too many selected file indices in \"0-5000000\"; " +
+			"the maximum is 1048576",
+	},
+	{
+		Name: "Range up to the largest int",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&" +
+			"so=0-9223372036854775807",
+		ExpectedIndices: nil,
+		ExpectedError: "Too many selected file indices in " +
+			"\"0-9223372036854775807\"; the maximum is 1048576",
+	},
+	{
+		Name: "Small range ending at the largest int",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&" +
+			"so=9223372036854775806-9223372036854775807",
+		ExpectedIndices: []int{9223372036854775806, 9223372036854775807},
+	},
+	{
+		Name:            "Ranges over the maximum in total",
+		RawMagnetURI:    "magnet:?xt=urn:btih:abc&so=0-1000000,2000000-2100000",
+		ExpectedIndices: nil,
+		ExpectedError: "Too many selected file indices in " +
+			"\"2000000-2100000\"; the maximum is 1048576",
+	},
+}
+
+func TestParseWithWhitespaceAndFragment(t *testing.T) {
+	scenarios := parseWithWhitespaceAndFragmentScenarios
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(expectedMagnetURI) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, expectedMagnetURI, magnetURI)
+		}
+	}
+}
+
+type parseWithWhitespaceAndFragmentScenario struct {
+	Name         string
+	RawMagnetURI string
+}
+
+var parseWithWhitespaceAndFragmentScenarios = []parseWithWhitespaceAndFragmentScenario{
+	{
+		Name: "Surrounding whitespace",
+		RawMagnetURI: " \t magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name\n",
+	},
+	{
+		Name: "Trailing fragment",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name#fragment",
+	},
+	{
+		Name: "Whitespace and fragment",
+		RawMagnetURI: " magnet:?" +
+			"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&dn=name#top ",
+	},
+}
+
+func TestParseHTMLEscapedSeparators(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:" +
+		"c12fe1c06bba254a9dc9f519b335aa7c1367a88a&amp;" +
+		"dn=Great+Speeches&amp;tr=udp%3A%2F%2Ftracker.example%3A6969"
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0,
+				"urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			Parameter{"dn", 0, "Great+Speeches"},
+			Parameter{"tr", 0, "udp%3A%2F%2Ftracker.example%3A6969"},
+		},
+	}
+	magnetURI, error := Parse(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	displayName, _ := magnetURI.DisplayName()
+	if displayName != "Great Speeches" {
+		t.Errorf("Expected display name %q; got %q",
+			"Great Speeches", displayName)
+	}
+}
+
+func TestParseSemicolonSeparators(t *testing.T) {
+	scenarios := parseSemicolonSeparatorsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !reflect.DeepEqual(magnetURI, scenario.ExpectedMagnetURI) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.ExpectedMagnetURI, magnetURI)
+		}
+		magnetURIString, _ := magnetURI.String()
+		if magnetURIString != scenario.ExpectedString {
+			t.Errorf("Error on test %q: expected string %q; got %q",
+				scenario.Name, scenario.ExpectedString, magnetURIString)
+		}
+	}
+}
+
+type parseSemicolonSeparatorsScenario struct {
+	Name              string
+	RawMagnetURI      string
+	ExpectedMagnetURI MagnetURI
+	ExpectedString    string
+}
+
+var parseSemicolonSeparatorsScenarios = []parseSemicolonSeparatorsScenario{
+	{
+		Name:         "Semicolon separator",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc;dn=name",
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"dn", 0, "name"},
+		}},
+		ExpectedString: "magnet:?xt=urn:btih:abc&dn=name",
+	},
+	{
+		Name:         "Mixed separators",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc;dn=name&kt=speech",
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"dn", 0, "name"},
+			{"kt", 0, "speech"},
+		}},
+		ExpectedString: "magnet:?xt=urn:btih:abc&dn=name&kt=speech",
+	},
+	{
+		Name: "URL value with semicolons",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc;" +
+			"ws=http://seed.example/file;v=1&x=2;dn=name",
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"ws", 0, "http://seed.example/file;v=1&x=2"},
+			{"dn", 0, "name"},
+		}},
+		ExpectedString: "magnet:?xt=urn:btih:abc&" +
+			"ws=http://seed.example/file;v=1&x=2&dn=name",
+	},
+	{
+		Name: "Display name with semicolon",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Foo;Bar",
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			{"dn", 0, "Foo;Bar"},
+		}},
+		ExpectedString: "magnet:?" +
+			"xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"dn=Foo%3BBar",
+	},
+	{
+		Name:         "Display name with semicolon and equals sign",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&dn=Foo;Bar=1;tr.1=udp://t",
+		ExpectedMagnetURI: MagnetURI{[]Parameter{
+			{"xt", 0, "urn:btih:abc"},
+			{"dn", 0, "Foo;Bar=1"},
+			{"tr", 1, "udp://t"},
+		}},
+		ExpectedString: "magnet:?xt=urn:btih:abc&dn=Foo%3BBar=1&tr.1=udp://t",
+	},
+}
+
+func TestParseQuery(t *testing.T) {
 	scenarios := magnetURIConvertionScenarios
 	for _, scenario := range scenarios {
-		magnetURIString, error := scenario.URIStruct.String()
+		query := strings.TrimPrefix(scenario.RawMagnetURI, "magnet:?")
+		magnetURI, error := ParseQuery(query)
 		if error != nil {
 			t.Errorf("There was an error on test %q: %q",
 				scenario.Name, error.Error())
 		}
-		if magnetURIString != scenario.RawMagnetURI {
-			t.Errorf("Error on test %q: expected Magnet URI: %q; got %q",
-				scenario.Name, scenario.RawMagnetURI, magnetURIString)
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+func TestParseQueryWithErrors(t *testing.T) {
+	magnetURI, error := ParseQuery("xt=urn:btih:abc&unknown=value")
+	expectedErrorMessage := "Unknown parameter prefix: \"unknown\""
+	if !magnetURI.Equal(MagnetURI{}) {
+		t.Errorf("A Magnet URI was returned: %v", magnetURI)
+	}
+	if error == nil {
+		t.Fatal("No error was returned.")
+	}
+	if error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message: %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+	var parseError *ParseError
+	if !errors.As(error, &parseError) ||
+		parseError.Raw != "xt=urn:btih:abc&unknown=value" {
+		t.Errorf("Expected a ParseError with the query; got %#v", error)
+	}
+	var positionError *PositionError
+	if !errors.As(error, &positionError) || positionError.Pos != 16 {
+		t.Errorf("Expected the error at position 16; got %#v", error)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	magnetURI, errors := ParseLenient("magnet:?" +
+		"xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+		"vendor=value&" +
+		"dn=name&" +
+		"withoutprefix&" +
+		"tr=http://tracker.example/announce")
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			Parameter{"dn", 0, "name"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		},
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	expectedErrors := []string{
+		"Unknown parameter prefix: \"vendor\"",
+		"Parameter without prefix: \"withoutprefix\"",
+	}
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("Expected errors: %q; got %q", expectedErrors, errors)
+	}
+	for i, error := range errors {
+		if error.Error() != expectedErrors[i] {
+			t.Errorf("Expected error message: %q; got %q",
+				expectedErrors[i], error.Error())
 		}
 	}
 }
+
+func TestParseLenientWithoutSchemaPrefix(t *testing.T) {
+	magnetURI, errors := ParseLenient("xt=urn:btih:abc")
+	if !magnetURI.Equal(MagnetURI{}) {
+		t.Errorf("A Magnet URI was returned: %v", magnetURI)
+	}
+	if len(errors) != 1 {
+		t.Errorf("Expected one error; got %q", errors)
+	}
+}
+
+func TestParseUppercasePrefixes(t *testing.T) {
+	magnetURI, error := Parse("magnet:?XT=urn:btih:ABC&Dn=Name&TR.1=http://T&X.PE=1.2.3.4:1")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:ABC"},
+			Parameter{"dn", 0, "Name"},
+			Parameter{"tr", 1, "http://T"},
+			Parameter{"x.pe", 0, "1.2.3.4:1"},
+		},
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	expectedString := "magnet:?xt=urn:btih:ABC&dn=Name&tr.1=http://T&x.pe=1.2.3.4:1"
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != expectedString {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			expectedString, magnetURIString)
+	}
+}
+
+func TestParseIndexWithLeadingZeros(t *testing.T) {
+	rawMagnetURI := "magnet:?xt.01=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		},
+	}
+	expectedString := "magnet:?xt.1=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"
+	magnetURI, error := Parse(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != expectedString {
+		t.Errorf("Expected Magnet URI: %q; got %q",
+			expectedString, magnetURIString)
+	}
+	reparsedMagnetURI, error := Parse(magnetURIString)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if !reparsedMagnetURI.Equal(magnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			magnetURI, reparsedMagnetURI)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, scenario := range magnetURIConvertionScenarios {
+		f.Add(scenario.RawMagnetURI)
+	}
+	for _, scenario := range parseMagnetURIWithErrorsScenarios {
+		f.Add(scenario.RawMagnetURI)
+	}
+	f.Fuzz(func(t *testing.T, rawMagnetURI string) {
+		magnetURI, error := Parse(rawMagnetURI)
+		if error != nil {
+			if !magnetURI.Equal(MagnetURI{}) {
+				t.Errorf("A Magnet URI was returned with the error %q: %v",
+					error.Error(), magnetURI)
+			}
+			return
+		}
+		for _, parameter := range magnetURI.Parameters {
+			if parameter.Index < 0 {
+				t.Errorf("Negative index in parameter %v", parameter)
+			}
+		}
+	})
+}
+
+// roundTripPrefixes are the prefixes with values that are not validated by
+// Parse, so any encoded value can be used with them.
+var roundTripPrefixes = []Prefix{
+	ExactTopic, DisplayName, KeywordTopic,
+	ManifestTopic, AddressTracker, WebSeed, ExactSource,
+	AcceptableSource, Peer, SelectOnly,
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add(uint8(0), uint32(0), "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		uint64(10826029))
+	f.Add(uint8(1), uint32(1), "a & b = c + d", uint64(0))
+	f.Add(uint8(4), uint32(2), "http://tracker.example.org/announce?a=1&b=2",
+		uint64(1))
+	f.Add(uint8(5), uint32(0), "100% #1; done", uint64(42))
+	f.Add(uint8(2), uint32(3), "max", uint64(1<<64-1))
+	f.Fuzz(func(t *testing.T, prefixSeed uint8, index uint32, value string,
+		length uint64) {
+		prefix := roundTripPrefixes[int(prefixSeed)%len(roundTripPrefixes)]
+		magnetURI := MagnetURI{[]Parameter{
+			NewParameter(prefix, int(index%(1<<maxIndexBits)), value),
+			{ExactLength, 0, strconv.FormatUint(length%(1<<63), 10)},
+		}}
+		magnetURIString, error := magnetURI.String()
+		if error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		parsedMagnetURI, error := Parse(magnetURIString)
+		if error != nil {
+			t.Fatalf("There was an error parsing %q: %q",
+				magnetURIString, error.Error())
+		}
+		if !parsedMagnetURI.Equal(magnetURI) {
+			t.Fatalf("Expected Magnet URI: %v; got %v",
+				magnetURI, parsedMagnetURI)
+		}
+		decodedValue, error := parsedMagnetURI.Parameters[0].DecodedValue()
+		if error != nil {
+			t.Fatalf("There was an error decoding: %q", error.Error())
+		}
+		if decodedValue != value {
+			t.Errorf("Expected decoded value: %q; got %q", value, decodedValue)
+		}
+	})
+}
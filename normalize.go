@@ -0,0 +1,100 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// Normalize returns a copy of the Magnet URI in a normal form, to compare and
+// store Magnet URIs. These transformations are applied, in order:
+//   - the "urn:btih:" and "urn:ed2k:" prefixes of the exact topics are
+//     lowercased;
+//   - the 40 characters hex BitTorrent info hashes and the eDonkey2000
+//     hashes are lowercased, and the 32 characters base32 BitTorrent info
+//     hashes are uppercased;
+//   - the duplicate parameters are removed, like with Dedup;
+//   - the parameters are sorted, like with Sort.
+//
+// No other value is changed, and normalizing a normalized Magnet URI returns
+// the same Magnet URI.
+func (magnetURI MagnetURI) Normalize() MagnetURI {
+	parameters := make([]Parameter, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == ExactTopic {
+			parameter.Value = normalizeExactTopic(parameter.Value)
+		}
+		parameters = append(parameters, parameter)
+	}
+	normalized := MagnetURI{Parameters: parameters}
+	normalized.Dedup()
+	normalized.Sort()
+	return normalized
+}
+
+func normalizeExactTopic(value string) string {
+	if hash, ok := trimPrefixFold(value, bitTorrentInfoHashURNPrefix); ok {
+		switch len(hash) {
+		case hex.EncodedLen(20):
+			hash = strings.ToLower(hash)
+		case base32.StdEncoding.EncodedLen(20):
+			hash = strings.ToUpper(hash)
+		}
+		return bitTorrentInfoHashURNPrefix + hash
+	}
+	if hash, ok := trimPrefixFold(value, eDonkeyHashURNPrefix); ok {
+		return eDonkeyHashURNPrefix + strings.ToLower(hash)
+	}
+	return value
+}
+
+// EqualFold returns true if the Magnet URIs are equal like with Equal, but
+// comparing the exact topics that are URNs without regard to case, false if
+// not. The hashes in URNs are written in hex or base32, that are case
+// insensitive, so "urn:sha1:ABC" and "urn:sha1:abc" are the same content.
+// The other values, like display names and keyword topics, are still
+// compared with case.
+func (magnetURI MagnetURI) EqualFold(x MagnetURI) bool {
+	return compareParameters(
+		foldExactTopics(magnetURI.Parameters), foldExactTopics(x.Parameters))
+}
+
+// foldExactTopics returns a copy of the parameters with the exact topics that
+// are URNs lowercased.
+func foldExactTopics(parameters []Parameter) []Parameter {
+	folded := make([]Parameter, len(parameters))
+	for i, parameter := range parameters {
+		if parameter.Prefix == ExactTopic {
+			if _, ok := urnScheme(parameter.Value); ok {
+				parameter.Value = strings.ToLower(parameter.Value)
+			}
+		}
+		folded[i] = parameter
+	}
+	return folded
+}
+
+// trimPrefixFold returns the value without the prefix, matched without
+// regard to case, and true if the value starts with the prefix.
+func trimPrefixFold(value string, prefix string) (string, bool) {
+	if len(value) < len(prefix) ||
+		!strings.EqualFold(value[:len(prefix)], prefix) {
+		return value, false
+	}
+	return value[len(prefix):], true
+}
@@ -0,0 +1,134 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+			Parameter{"dn", 0, "Name"},
+			Parameter{"xt", 3, "urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1"},
+			Parameter{"xt", 2, "urn:btih:yex6dqdlxisuvhoj6um3gnnkpqjwpkek"},
+			Parameter{"xt", 1, "URN:BTIH:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"},
+			Parameter{"xt", 1, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			Parameter{"xt", 4, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			Parameter{"tr", 0, "http://tracker.example/announce"},
+		},
+	}
+	expectedParameters := []Parameter{
+		Parameter{"xt", 1, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+		Parameter{"xt", 2, "urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK"},
+		Parameter{"xt", 3, "urn:ed2k:354b15e68fb8f36d7cd88ff94116cdc1"},
+		Parameter{"xt", 4, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		Parameter{"dn", 0, "Name"},
+		Parameter{"tr", 0, "http://tracker.example/announce"},
+	}
+	normalized := magnetURI.Normalize()
+	if len(normalized.Parameters) != len(expectedParameters) {
+		t.Fatalf("Expected parameters: %v; got %v",
+			expectedParameters, normalized.Parameters)
+	}
+	for i, parameter := range normalized.Parameters {
+		if parameter != expectedParameters[i] {
+			t.Errorf("Expected parameter %v; got %v",
+				expectedParameters[i], parameter)
+		}
+	}
+	renormalized := normalized.Normalize()
+	if !renormalized.Equal(normalized) {
+		t.Errorf("Normalize is not idempotent: %v; got %v",
+			normalized, renormalized)
+	}
+	if magnetURI.Parameters[0].Prefix != "tr" {
+		t.Error("Normalize modified the original Magnet URI.")
+	}
+}
+
+func TestEqualFold(t *testing.T) {
+	scenarios := equalFoldScenarios
+	for _, scenario := range scenarios {
+		result := scenario.FirstMagnetURI.EqualFold(scenario.SecondMagnetURI)
+		if result != scenario.ExpectedResult {
+			t.Errorf(
+				"Error on test %q: comparing %v and %v returns %t.",
+				scenario.Name, scenario.FirstMagnetURI,
+				scenario.SecondMagnetURI, result)
+		}
+	}
+}
+
+var equalFoldScenarios = []compareMagnetURIsScenario{
+	{
+		Name: "Hashes with different case",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 1, "urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			Parameter{"xt", 2, "URN:BTIH:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A"},
+			Parameter{"dn", 0, "Name"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"dn", 0, "Name"},
+			Parameter{"xt", 2, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			Parameter{"xt", 1, "urn:sha1:ynckhtqcwbtrnjiv4wnae52sjuqczo5c"},
+		}},
+		ExpectedResult: true,
+	},
+	{
+		Name: "Display names with different case",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:sha1:ABC"},
+			Parameter{"dn", 0, "Name"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:sha1:abc"},
+			Parameter{"dn", 0, "name"},
+		}},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Keyword topics with different case",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"kt", 0, "Martin"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"kt", 0, "martin"},
+		}},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Exact topics that are not URNs",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "http://example.com/File"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "http://example.com/file"},
+		}},
+		ExpectedResult: false,
+	},
+	{
+		Name: "Different hashes",
+		FirstMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:sha1:ABC"},
+		}},
+		SecondMagnetURI: MagnetURI{[]Parameter{
+			Parameter{"xt", 0, "urn:sha1:abd"},
+		}},
+		ExpectedResult: false,
+	},
+}
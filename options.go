@@ -0,0 +1,55 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+// ParseOptions control how ParseWithOptions parses a raw Magnet URI string.
+// The zero value parses like Parse.
+type ParseOptions struct {
+	// Lenient skips the parameters that can't be parsed instead of making
+	// the whole Magnet URI fail. The default is false. Use ParseLenient to
+	// get the errors of the skipped parameters.
+	Lenient bool
+	// DisallowSemicolonSeparators makes ";" part of the parameters instead
+	// of a separator, like some old generators use it. The default is
+	// false, so ";" separates parameters like "&".
+	DisallowSemicolonSeparators bool
+	// DisallowHTMLEntities keeps the "&amp;" HTML entities, found in
+	// Magnet URIs copied from HTML, instead of decoding them to "&"
+	// separators. The default is false, so they are decoded.
+	DisallowHTMLEntities bool
+	// MaxParameters is the largest number of parameters accepted, to limit
+	// the work done on untrusted input. The default is 0, that means there
+	// is no limit.
+	MaxParameters int
+	// ValueTransform, if it is not nil, is called with the prefix and the
+	// value of each parameter, before checking the value, and the value
+	// returned is stored in the Parameter. The values are encoded, as they
+	// appear in the raw string. Returning an error makes the parse fail,
+	// with the prefix of the parameter in the error, or skips the
+	// parameter if Lenient is true. The default is nil, so the values are
+	// stored unchanged.
+	ValueTransform func(prefix Prefix, value string) (string, error)
+}
+
+// ParseWithOptions parses a raw Magnet URI string into a MagnetURI structure
+// like Parse, with the behavior changed by the options.
+// The errors are returned as *ParseError, with the raw string.
+// The decoding of the parameter values is controlled separately, with
+// DecodeOptions.
+func ParseWithOptions(rawMagnetURI string, options ParseOptions) (MagnetURI, error) {
+	magnetURI, _, err := parse(rawMagnetURI, options)
+	return magnetURI, err
+}
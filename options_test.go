@@ -0,0 +1,167 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWithDefaultOptions(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := ParseWithOptions(
+			scenario.RawMagnetURI, ParseOptions{})
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+func TestParseWithOptions(t *testing.T) {
+	scenarios := parseWithOptionsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := ParseWithOptions(
+			scenario.RawMagnetURI, scenario.Options)
+		errorMessage := ""
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+		if !reflect.DeepEqual(magnetURI.Parameters,
+			scenario.ExpectedParameters) {
+			t.Errorf("Error on test %q: expected parameters %v; got %v",
+				scenario.Name, scenario.ExpectedParameters,
+				magnetURI.Parameters)
+		}
+	}
+}
+
+type parseWithOptionsScenario struct {
+	Name               string
+	RawMagnetURI       string
+	Options            ParseOptions
+	ExpectedParameters []Parameter
+	ExpectedError      string
+}
+
+var parseWithOptionsScenarios = []parseWithOptionsScenario{
+	{
+		Name:         "Lenient",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&broken&dn=name",
+		Options:      ParseOptions{Lenient: true},
+		ExpectedParameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+		},
+	},
+	{
+		Name:         "Semicolons disallowed",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc;dn=name",
+		Options:      ParseOptions{DisallowSemicolonSeparators: true},
+		ExpectedParameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc;dn=name"},
+		},
+	},
+	{
+		Name:          "HTML entities disallowed",
+		RawMagnetURI:  "magnet:?xt=urn:btih:abc&amp;dn=name",
+		Options:       ParseOptions{DisallowHTMLEntities: true},
+		ExpectedError: "Parameter without prefix: \"amp\"",
+	},
+	{
+		Name:         "Parameters under the maximum",
+		RawMagnetURI: "magnet:?xt=urn:btih:abc&dn=name",
+		Options:      ParseOptions{MaxParameters: 2},
+		ExpectedParameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+		},
+	},
+	{
+		Name:          "Too many parameters",
+		RawMagnetURI:  "magnet:?xt=urn:btih:abc&dn=name&tr=udp://tracker",
+		Options:       ParseOptions{MaxParameters: 2},
+		ExpectedError: "Too many parameters: 3; the maximum is 2",
+	},
+}
+
+func transformValue(prefix Prefix, value string) (string, error) {
+	switch prefix {
+	case ExactTopic:
+		return strings.ToLower(value), nil
+	case DisplayName:
+		return strings.Trim(value, "+"), nil
+	case AddressTracker:
+		if strings.HasPrefix(value, "http:") {
+			return "", errors.New("Insecure tracker")
+		}
+	}
+	return value, nil
+}
+
+func TestParseWithValueTransform(t *testing.T) {
+	magnetURI, error := ParseWithOptions("magnet:?"+
+		"xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&"+
+		"dn=+name+&tr=https://tracker.example/announce",
+		ParseOptions{ValueTransform: transformValue})
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedParameters := []Parameter{
+		Parameter{"xt", 0, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+		Parameter{"dn", 0, "name"},
+		Parameter{"tr", 0, "https://tracker.example/announce"},
+	}
+	if !reflect.DeepEqual(magnetURI.Parameters, expectedParameters) {
+		t.Errorf("Expected parameters %v; got %v",
+			expectedParameters, magnetURI.Parameters)
+	}
+}
+
+func TestParseWithValueTransformError(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&tr.1=http://tracker.example/announce"
+	_, error := ParseWithOptions(rawMagnetURI,
+		ParseOptions{ValueTransform: transformValue})
+	expectedErrorMessage := "Wrong value of parameter \"tr.1\"; Insecure tracker"
+	if error == nil || error.Error() != expectedErrorMessage {
+		t.Fatalf("Expected error message %q; got %v",
+			expectedErrorMessage, error)
+	}
+	var positionError *PositionError
+	if !errors.As(error, &positionError) || positionError.Pos != 24 {
+		t.Errorf("Expected the error at position 24; got %#v", error)
+	}
+	magnetURI, error := ParseWithOptions(rawMagnetURI,
+		ParseOptions{ValueTransform: transformValue, Lenient: true})
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(magnetURI.Parameters) != 1 {
+		t.Errorf("Expected the tracker to be skipped; got %v", magnetURI)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// prefixDescriptions are the human descriptions of the known prefixes.
+var prefixDescriptions = map[Prefix]string{
+	ExactTopic:       "exact topic",
+	ExactLength:      "exact length",
+	DisplayName:      "display name",
+	KeywordTopic:     "keyword topic",
+	ManifestTopic:    "manifest topic",
+	AddressTracker:   "address tracker",
+	WebSeed:          "web seed",
+	ExactSource:      "exact source",
+	AcceptableSource: "acceptable source",
+	Peer:             "peer address",
+	SelectOnly:       "select only",
+	DHTNode:          "DHT node",
+	ExtendedDHTNode:  "DHT node",
+}
+
+var (
+	registeredPrefixesMutex sync.RWMutex
+	registeredPrefixes      = make(map[Prefix]bool)
+)
+
+// RegisterPrefix makes Parse accept parameters with a custom prefix, like a
+// vendor or experimental extension. The registration is global to the process
+// and it's safe to call from several goroutines.
+// Prefixes are case insensitive, so they are registered in lowercase.
+func RegisterPrefix(prefix Prefix) error {
+	prefix = Prefix(strings.ToLower(string(prefix)))
+	if prefix == "" || strings.ContainsAny(string(prefix), "=&;#") {
+		return errors.New(fmt.Sprintf("Wrong custom prefix: %q", prefix))
+	}
+	registeredPrefixesMutex.Lock()
+	defer registeredPrefixesMutex.Unlock()
+	registeredPrefixes[prefix] = true
+	return nil
+}
+
+// UnregisterPrefix removes a prefix registered with RegisterPrefix.
+// The prefixes built into the package can't be removed.
+func UnregisterPrefix(prefix Prefix) error {
+	prefix = Prefix(strings.ToLower(string(prefix)))
+	if _, ok := prefixDescriptions[prefix]; ok {
+		return errors.New(
+			fmt.Sprintf("Built-in prefix can't be unregistered: %q", prefix))
+	}
+	registeredPrefixesMutex.Lock()
+	defer registeredPrefixesMutex.Unlock()
+	delete(registeredPrefixes, prefix)
+	return nil
+}
+
+// KnownPrefixes returns the list of parameter prefixes that are parsed: the
+// built-in prefixes in their canonical order, followed by the registered
+// prefixes in alphabetical order.
+func KnownPrefixes() []Prefix {
+	prefixes := make([]Prefix, len(canonicalPrefixOrder))
+	copy(prefixes, canonicalPrefixOrder)
+	registeredPrefixesMutex.RLock()
+	defer registeredPrefixesMutex.RUnlock()
+	customPrefixes := make([]Prefix, 0, len(registeredPrefixes))
+	for prefix := range registeredPrefixes {
+		if !isBuiltInPrefix(prefix) {
+			customPrefixes = append(customPrefixes, prefix)
+		}
+	}
+	sort.Slice(customPrefixes, func(i, j int) bool {
+		return customPrefixes[i] < customPrefixes[j]
+	})
+	return append(prefixes, customPrefixes...)
+}
+
+// PrefixDescription returns a human description of the parameter prefix, like
+// "exact topic" for "xt", and true if the prefix is built into the package.
+func PrefixDescription(prefix Prefix) (string, bool) {
+	description, ok := prefixDescriptions[prefix]
+	return description, ok
+}
+
+// NonStandardParameters returns the parameters of the Magnet URI with
+// prefixes that are not built into the package, like the ones registered with
+// RegisterPrefix. These are the parameters removed by StripNonStandard.
+func (magnetURI *MagnetURI) NonStandardParameters() []Parameter {
+	var parameters []Parameter
+	for _, parameter := range magnetURI.Parameters {
+		if !isBuiltInPrefix(parameter.Prefix) {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return parameters
+}
+
+// StripNonStandard returns a copy of the Magnet URI with only the parameters
+// with prefixes built into the package, to share it without vendor
+// extensions.
+func (magnetURI MagnetURI) StripNonStandard() MagnetURI {
+	parameters := make([]Parameter, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		if isBuiltInPrefix(parameter.Prefix) {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return MagnetURI{Parameters: parameters}
+}
+
+// Minimal returns a copy of the Magnet URI with only its exact topics and
+// display names, to share it without address trackers, sources or peers.
+func (magnetURI MagnetURI) Minimal() MagnetURI {
+	parameters := make([]Parameter, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		if parameter.Prefix == ExactTopic ||
+			parameter.Prefix == DisplayName {
+			parameters = append(parameters, parameter)
+		}
+	}
+	return MagnetURI{Parameters: parameters}
+}
+
+func isBuiltInPrefix(prefix Prefix) bool {
+	_, ok := prefixDescriptions[prefix]
+	return ok
+}
+
+func isValidPrefix(prefix Prefix) bool {
+	if isBuiltInPrefix(prefix) {
+		return true
+	}
+	registeredPrefixesMutex.RLock()
+	defer registeredPrefixesMutex.RUnlock()
+	return registeredPrefixes[prefix]
+}
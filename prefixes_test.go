@@ -0,0 +1,204 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestKnownPrefixes(t *testing.T) {
+	prefixes := KnownPrefixes()
+	if len(prefixes) < len(prefixDescriptions) {
+		t.Errorf("Expected %d prefixes; got %q",
+			len(prefixDescriptions), prefixes)
+	}
+	for _, prefix := range prefixes {
+		if !isValidPrefix(prefix) {
+			t.Errorf("The known prefix %q is not valid", prefix)
+		}
+	}
+	for _, prefix := range prefixes[:len(prefixDescriptions)] {
+		if _, ok := PrefixDescription(prefix); !ok {
+			t.Errorf("The built-in prefix %q has no description", prefix)
+		}
+	}
+	prefixes[0] = "modified"
+	if KnownPrefixes()[0] != "xt" {
+		t.Error("The known prefixes can be modified.")
+	}
+}
+
+func TestPrefixDescription(t *testing.T) {
+	scenarios := prefixDescriptionScenarios
+	for _, scenario := range scenarios {
+		description, ok := PrefixDescription(scenario.Prefix)
+		if ok != scenario.ExpectedOk {
+			t.Errorf("Error on prefix %q: expected %t; got %t",
+				scenario.Prefix, scenario.ExpectedOk, ok)
+		}
+		if description != scenario.ExpectedDescription {
+			t.Errorf("Error on prefix %q: expected description %q; got %q",
+				scenario.Prefix, scenario.ExpectedDescription, description)
+		}
+	}
+}
+
+type prefixDescriptionScenario struct {
+	Prefix              Prefix
+	ExpectedDescription string
+	ExpectedOk          bool
+}
+
+var prefixDescriptionScenarios = []prefixDescriptionScenario{
+	{"xt", "exact topic", true},
+	{"dn", "display name", true},
+	{"tr", "address tracker", true},
+	{"x.pe", "peer address", true},
+	{"unknown", "", false},
+}
+
+func TestRegisterPrefix(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&x.vendor=value"
+	if _, error := Parse(rawMagnetURI); error == nil {
+		t.Fatal("The unregistered prefix was accepted.")
+	}
+	if error := RegisterPrefix("X.Vendor"); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	defer UnregisterPrefix("x.vendor")
+	magnetURI, error := Parse(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"x.vendor", 0, "value"},
+		},
+	}
+	if !magnetURI.Equal(expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, magnetURI)
+	}
+	prefixes := KnownPrefixes()
+	if prefixes[len(prefixes)-1] != "x.vendor" {
+		t.Errorf("The registered prefix is not known: %q", prefixes)
+	}
+	if error := UnregisterPrefix("x.vendor"); error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if _, error := Parse(rawMagnetURI); error == nil {
+		t.Error("The unregistered prefix was accepted.")
+	}
+}
+
+func TestRegisterWrongPrefix(t *testing.T) {
+	for _, prefix := range []Prefix{"", "a=b", "a&b"} {
+		if error := RegisterPrefix(prefix); error == nil {
+			t.Errorf("The wrong prefix %q was registered.", prefix)
+		}
+	}
+}
+
+func TestUnregisterBuiltInPrefix(t *testing.T) {
+	error := UnregisterPrefix("xt")
+	expectedErrorMessage := "Built-in prefix can't be unregistered: \"xt\""
+	if error == nil {
+		t.Fatal("No error was returned.")
+	}
+	if error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message: %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+	if !isValidPrefix("xt") {
+		t.Error("The built-in prefix was unregistered.")
+	}
+}
+
+func TestRegisterPrefixConcurrently(t *testing.T) {
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			RegisterPrefix("x.concurrent")
+			isValidPrefix("x.concurrent")
+			KnownPrefixes()
+			UnregisterPrefix("x.concurrent")
+		}()
+	}
+	wait.Wait()
+}
+
+func TestStripNonStandard(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"x.vendor", 0, "tracking"},
+			Parameter{"x.pe", 0, "192.0.2.1:6881"},
+			Parameter{"utm", 1, "campaign"},
+		},
+	}
+	expectedNonStandard := []Parameter{
+		Parameter{"x.vendor", 0, "tracking"},
+		Parameter{"utm", 1, "campaign"},
+	}
+	nonStandard := magnetURI.NonStandardParameters()
+	if !reflect.DeepEqual(nonStandard, expectedNonStandard) {
+		t.Errorf("Expected non standard parameters: %v; got %v",
+			expectedNonStandard, nonStandard)
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"x.pe", 0, "192.0.2.1:6881"},
+		},
+	}
+	stripped := magnetURI.StripNonStandard()
+	if !reflect.DeepEqual(stripped, expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, stripped)
+	}
+	if magnetURI.Len() != 4 {
+		t.Error("StripNonStandard modified the original Magnet URI.")
+	}
+}
+
+func TestMinimal(t *testing.T) {
+	magnetURI, error := Parse("magnet:?xt.1=urn:btih:abc&dn=name" +
+		"&tr=http://tracker.example/announce&xt.2=urn:sha1:def" +
+		"&x.pe=192.0.2.1:6881&ws=http://seed.example/file&xl=10")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedMagnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 1, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+			Parameter{"xt", 2, "urn:sha1:def"},
+		},
+	}
+	minimal := magnetURI.Minimal()
+	if !reflect.DeepEqual(minimal, expectedMagnetURI) {
+		t.Errorf("Expected Magnet URI: %v; got %v",
+			expectedMagnetURI, minimal)
+	}
+	if magnetURI.Len() != 7 {
+		t.Error("Minimal modified the original Magnet URI.")
+	}
+}
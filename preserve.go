@@ -0,0 +1,77 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"strings"
+)
+
+// PreservedMagnetURI is a Magnet URI that remembers the raw text of the
+// parameters it was parsed from, so it can be rewritten minimally.
+// Its parameters can be changed like the ones of a MagnetURI.
+type PreservedMagnetURI struct {
+	MagnetURI
+	raw map[Parameter][]string
+}
+
+// ParsePreserved parses a raw Magnet URI string like Parse, and records the
+// raw text of each parameter, like "dn=A%20B".
+func ParsePreserved(rawMagnetURI string) (PreservedMagnetURI, error) {
+	magnetURI, queryParameters, err := parse(rawMagnetURI, ParseOptions{})
+	if err != nil {
+		return PreservedMagnetURI{}, err
+	}
+	raw := make(map[Parameter][]string, len(queryParameters))
+	for i, parameter := range magnetURI.Parameters {
+		raw[parameter] = append(raw[parameter], queryParameters[i].text)
+	}
+	return PreservedMagnetURI{magnetURI, raw}, nil
+}
+
+// RawParameter returns the raw text that the parameter was parsed from, and
+// true if the parameter was parsed and hasn't changed.
+func (magnetURI *PreservedMagnetURI) RawParameter(
+	parameter Parameter) (string, bool) {
+	raw := magnetURI.raw[parameter]
+	if len(raw) == 0 {
+		return "", false
+	}
+	return raw[0], true
+}
+
+// String reassembles the Magnet URI into a valid Magnet URI string, in the
+// order of its parameters. The parameters that haven't changed since they
+// were parsed are written byte for byte as their raw text, and the rest are
+// written like in MagnetURI.String.
+func (magnetURI *PreservedMagnetURI) String() (string, error) {
+	if magnetURI.IsEmpty() {
+		return "", errors.New("The Magnet URI has no parameters.")
+	}
+	used := make(map[Parameter]int)
+	parameterStrings := make([]string, 0, len(magnetURI.Parameters))
+	for _, parameter := range magnetURI.Parameters {
+		raw := magnetURI.raw[parameter]
+		if used[parameter] < len(raw) {
+			parameterStrings = append(
+				parameterStrings, raw[used[parameter]])
+			used[parameter]++
+			continue
+		}
+		parameterStrings = append(parameterStrings, parameter.String())
+	}
+	return magnetURISchemaPrefix + strings.Join(parameterStrings, "&"), nil
+}
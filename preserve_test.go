@@ -0,0 +1,73 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"testing"
+)
+
+func TestParsePreserved(t *testing.T) {
+	rawMagnetURI := "magnet:?xt=urn:btih:abc&dn=A%20B&" +
+		"tr=http://tracker.example/announce?a=1&b=2&DN=Other"
+	magnetURI, error := ParsePreserved(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != rawMagnetURI {
+		t.Errorf("Expected Magnet URI %q; got %q",
+			rawMagnetURI, magnetURIString)
+	}
+	raw, present := magnetURI.RawParameter(Parameter{"dn", 0, "Other"})
+	if !present || raw != "DN=Other" {
+		t.Errorf("Expected raw parameter %q; got %q", "DN=Other", raw)
+	}
+}
+
+func TestParsePreservedWithChanges(t *testing.T) {
+	magnetURI, error := ParsePreserved(
+		"magnet:?xt=urn:btih:abc&DN=A%20B&tr=http://tracker.example/a")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	magnetURI.Parameters[2].Value = "http://tracker.example/b c"
+	magnetURI.Parameters = append(
+		magnetURI.Parameters, Parameter{"kt", 0, "speech"})
+	expectedMagnetURI := "magnet:?xt=urn:btih:abc&DN=A%20B&" +
+		"tr=http://tracker.example/b%20c&kt=speech"
+	magnetURIString, error := magnetURI.String()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if magnetURIString != expectedMagnetURI {
+		t.Errorf("Expected Magnet URI %q; got %q",
+			expectedMagnetURI, magnetURIString)
+	}
+	if _, present := magnetURI.RawParameter(
+		Parameter{"kt", 0, "speech"}); present {
+		t.Error("The added parameter has a raw text.")
+	}
+}
+
+func TestParsePreservedWithErrors(t *testing.T) {
+	_, error := ParsePreserved("magnet:?unknown=value")
+	if error == nil {
+		t.Error("No error was returned.")
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const magnetURIScheme = "magnet"
+
+// ToURL returns the Magnet URI as a URL with the "magnet" scheme.
+// The query of the URL is built from the parameters in order, keeping the
+// indexed prefixes like "xt.1".
+func (magnetURI MagnetURI) ToURL() (*url.URL, error) {
+	s, err := magnetURI.String()
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{
+		Scheme:   magnetURIScheme,
+		RawQuery: strings.TrimPrefix(s, magnetURISchemaPrefix),
+	}, nil
+}
+
+// FromURL returns the Magnet URI represented by a URL with the "magnet"
+// scheme.
+func FromURL(u *url.URL) (MagnetURI, error) {
+	if u.Scheme != magnetURIScheme || u.Opaque != "" || u.Host != "" ||
+		u.Path != "" {
+		return MagnetURI{}, &SchemaPrefixError{}
+	}
+	return Parse(magnetURISchemaPrefix + u.RawQuery)
+}
+
+// URLValueError is returned when the value of a parameter with a URL, like an
+// address tracker, is not a valid absolute URL.
+type URLValueError struct {
+	Index int // The position of the value in the list of its prefix.
+	Value string
+	Err   error
+}
+
+func (err *URLValueError) Error() string {
+	return fmt.Sprintf("Wrong URL at index %d: %q; %s",
+		err.Index, err.Value, err.Err.Error())
+}
+
+// Unwrap returns the error of parsing the URL.
+func (err *URLValueError) Unwrap() error {
+	return err.Err
+}
+
+// TrackerURLs returns the parsed URLs of the address trackers of the Magnet
+// URI, in the order of Trackers. The values that are not valid absolute URLs
+// are returned as nil, along with a *URLValueError for each of them, joined
+// in the returned error.
+// Values that are percent-encoded as a whole, like
+// "udp%3A%2F%2Ftracker.example%3A6969", are decoded before parsing them.
+func (magnetURI *MagnetURI) TrackerURLs() ([]*url.URL, error) {
+	return parseURLValues(magnetURI.Trackers())
+}
+
+// WebSeedURLs returns the parsed URLs of the web seeds of the Magnet URI, like
+// TrackerURLs.
+func (magnetURI *MagnetURI) WebSeedURLs() ([]*url.URL, error) {
+	return parseURLValues(magnetURI.WebSeeds())
+}
+
+// ExactSourceURLs returns the parsed URLs of the exact sources of the Magnet
+// URI, like TrackerURLs.
+func (magnetURI *MagnetURI) ExactSourceURLs() ([]*url.URL, error) {
+	return parseURLValues(magnetURI.ExactSources())
+}
+
+// AcceptableSourceURLs returns the parsed URLs of the acceptable sources of
+// the Magnet URI, like TrackerURLs.
+func (magnetURI *MagnetURI) AcceptableSourceURLs() ([]*url.URL, error) {
+	return parseURLValues(magnetURI.AcceptableSources())
+}
+
+// ManifestTopicURLs returns the parsed URLs of the manifest topics of the
+// Magnet URI, like TrackerURLs. Use ResolveManifest to fetch the manifests.
+func (magnetURI *MagnetURI) ManifestTopicURLs() ([]*url.URL, error) {
+	return parseURLValues(magnetURI.ManifestTopics())
+}
+
+func parseURLValues(parameters []Parameter) ([]*url.URL, error) {
+	urls := make([]*url.URL, len(parameters))
+	var errs []error
+	for i, parameter := range parameters {
+		u, err := parseURLValue(parameter.Value)
+		if err != nil {
+			errs = append(errs, &URLValueError{i, parameter.Value, err})
+			continue
+		}
+		urls[i] = u
+	}
+	return urls, errors.Join(errs...)
+}
+
+func parseURLValue(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		decodedValue, err := url.PathUnescape(value)
+		if err != nil {
+			return nil, err
+		}
+		value = decodedValue
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, errors.New("The URL is not absolute")
+	}
+	return u, nil
+}
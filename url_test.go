@@ -0,0 +1,167 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestToURL(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		u, error := scenario.URIStruct.ToURL()
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if u.Scheme != "magnet" {
+			t.Errorf("Error on test %q: expected scheme %q; got %q",
+				scenario.Name, "magnet", u.Scheme)
+		}
+		if u.String() != scenario.RawMagnetURI {
+			t.Errorf("Error on test %q: expected URL: %q; got %q",
+				scenario.Name, scenario.RawMagnetURI, u.String())
+		}
+	}
+}
+
+func TestToURLWithoutParameters(t *testing.T) {
+	u, error := MagnetURI{}.ToURL()
+	if u != nil {
+		t.Errorf("A URL was returned: %v", u)
+	}
+	if error == nil {
+		t.Error("No error was returned.")
+	}
+}
+
+func TestFromURL(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		u, error := url.Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		magnetURI, error := FromURL(u)
+		if error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		if !magnetURI.Equal(scenario.URIStruct) {
+			t.Errorf("Error on test %q: expected Magnet URI: %v; got %v",
+				scenario.Name, scenario.URIStruct, magnetURI)
+		}
+	}
+}
+
+func TestFromURLWithWrongScheme(t *testing.T) {
+	u, error := url.Parse("http://example.com/?xt=urn:btih:abc")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	magnetURI, error := FromURL(u)
+	if !magnetURI.Equal(MagnetURI{}) {
+		t.Errorf("A Magnet URI was returned: %v", magnetURI)
+	}
+	if _, ok := error.(*SchemaPrefixError); !ok {
+		t.Errorf("Expected a SchemaPrefixError; got %#v", error)
+	}
+}
+
+func TestTrackerURLs(t *testing.T) {
+	magnetURI, error := Parse("magnet:?xt=urn:btih:abc" +
+		"&tr.1=udp%3A%2F%2Ftracker.example%3A6969%2Fannounce" +
+		"&tr.2=not+a+url" +
+		"&tr.3=http://tracker.example/announce?a=1&b=2")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	urls, error := magnetURI.TrackerURLs()
+	expectedURLs := []string{
+		"udp://tracker.example:6969/announce",
+		"",
+		"http://tracker.example/announce?a=1&b=2",
+	}
+	if len(urls) != len(expectedURLs) {
+		t.Fatalf("Expected URLs: %q; got %v", expectedURLs, urls)
+	}
+	for i, u := range urls {
+		urlString := ""
+		if u != nil {
+			urlString = u.String()
+		}
+		if urlString != expectedURLs[i] {
+			t.Errorf("Expected URL %q; got %q", expectedURLs[i], urlString)
+		}
+	}
+	var urlValueError *URLValueError
+	if !errors.As(error, &urlValueError) {
+		t.Fatalf("Expected a URLValueError; got %#v", error)
+	}
+	if urlValueError.Index != 1 {
+		t.Errorf("Expected index 1; got %d", urlValueError.Index)
+	}
+	expectedErrorMessage := "Wrong URL at index 1: \"not+a+url\"; " +
+		"The URL is not absolute"
+	if error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message %q; got %q",
+			expectedErrorMessage, error.Error())
+	}
+}
+
+func TestWebSeedURLs(t *testing.T) {
+	magnetURI, error := Parse("magnet:?xt=urn:btih:abc" +
+		"&ws=http%3A%2F%2Fseed.example%2Ffile&xs=http://source.example/file")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	urls, error := magnetURI.WebSeedURLs()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(urls) != 1 || urls[0].Host != "seed.example" {
+		t.Errorf("Expected the web seed URL; got %v", urls)
+	}
+	urls, error = magnetURI.ExactSourceURLs()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(urls) != 1 || urls[0].Host != "source.example" {
+		t.Errorf("Expected the exact source URL; got %v", urls)
+	}
+	urls, error = magnetURI.AcceptableSourceURLs()
+	if error != nil || len(urls) != 0 {
+		t.Errorf("Expected no acceptable source URLs; got %v, %v", urls, error)
+	}
+}
+
+func TestManifestTopicURLs(t *testing.T) {
+	magnetURI, error := Parse(
+		"magnet:?mt=http://weblog.foo/all-my-favorites.rss")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	urls, error := magnetURI.ManifestTopicURLs()
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(urls) != 1 || urls[0].Path != "/all-my-favorites.rss" {
+		t.Errorf("Expected the manifest topic URL; got %v", urls)
+	}
+}
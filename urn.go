@@ -0,0 +1,143 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const urnPrefix = "urn:"
+
+// URNSchemes returns the distinct URN namespace identifiers of the exact
+// topics of the Magnet URI, like "btih", "sha1" or "ed2k", in the order they
+// first appear. The namespace identifiers are case insensitive, so they are
+// returned in lowercase. Exact topics that are not URNs are ignored; they are
+// returned by NonURNExactTopics.
+func (magnetURI *MagnetURI) URNSchemes() []string {
+	var schemes []string
+	seen := make(map[string]bool)
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		scheme, ok := urnScheme(exactTopic.Value)
+		if !ok || seen[scheme] {
+			continue
+		}
+		seen[scheme] = true
+		schemes = append(schemes, scheme)
+	}
+	return schemes
+}
+
+// NonURNExactTopics returns the exact topic parameters of the Magnet URI with
+// values that are not URNs.
+func (magnetURI *MagnetURI) NonURNExactTopics() []Parameter {
+	var exactTopics []Parameter
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		if _, ok := urnScheme(exactTopic.Value); !ok {
+			exactTopics = append(exactTopics, exactTopic)
+		}
+	}
+	return exactTopics
+}
+
+// Hash is a hash of the content of a Magnet URI, from an exact topic URN.
+type Hash struct {
+	Algorithm string // Like "btih", "sha1" or "tree:tiger", in lowercase.
+	Value     string
+}
+
+// Hashes returns the hashes of the exact topics of the Magnet URI that are
+// URNs, in order. The algorithm is the part of the URN between "urn:" and the
+// last ":", so "urn:tree:tiger:ABC" has the "tree:tiger" algorithm, and the
+// value is the rest. Exact topics that are not URNs, or that have an empty
+// hash, are skipped.
+func (magnetURI *MagnetURI) Hashes() []Hash {
+	var hashes []Hash
+	for _, exactTopic := range magnetURI.ExactTopics() {
+		rest, ok := trimPrefixFold(exactTopic.Value, urnPrefix)
+		if !ok {
+			continue
+		}
+		i := strings.LastIndex(rest, ":")
+		if i <= 0 || i == len(rest)-1 {
+			continue
+		}
+		hashes = append(hashes, Hash{strings.ToLower(rest[:i]), rest[i+1:]})
+	}
+	return hashes
+}
+
+// urnScheme returns the lowercase namespace identifier of the URN, the token
+// between "urn:" and the next ":", and true if the value is a URN.
+func urnScheme(value string) (string, bool) {
+	rest, ok := trimPrefixFold(value, urnPrefix)
+	if !ok {
+		return "", false
+	}
+	i := strings.Index(rest, ":")
+	if i <= 0 {
+		return "", false
+	}
+	return strings.ToLower(rest[:i]), true
+}
+
+// URN is a uniform resource name, like the value "urn:btih:HASH" of an exact
+// topic.
+type URN struct {
+	Namespace  string // Like "btih" or "sha1", in lowercase.
+	Identifier string
+}
+
+func (urn URN) String() string {
+	return urnPrefix + urn.Namespace + ":" + urn.Identifier
+}
+
+// ExactTopicURNs returns the parsed URNs of the exact topics of the Magnet
+// URI, in the order of ExactTopics. The namespace is the token between "urn:"
+// and the next ":", and the identifier is the rest. The values that are not
+// URNs with a namespace and an identifier are returned as an empty URN, along
+// with an error with their index for each of them, joined in the returned
+// error.
+func (magnetURI *MagnetURI) ExactTopicURNs() ([]URN, error) {
+	exactTopics := magnetURI.ExactTopics()
+	urns := make([]URN, len(exactTopics))
+	var errs []error
+	for i, exactTopic := range exactTopics {
+		urn, ok := parseURN(exactTopic.Value)
+		if !ok {
+			errs = append(errs, errors.New(fmt.Sprintf(
+				"Wrong URN at index %d: %q", i, exactTopic.Value)))
+			continue
+		}
+		urns[i] = urn
+	}
+	return urns, errors.Join(errs...)
+}
+
+// parseURN parses a value like "urn:btih:HASH", and returns true if it is a
+// URN with a namespace and an identifier.
+func parseURN(value string) (URN, bool) {
+	namespace, ok := urnScheme(value)
+	if !ok {
+		return URN{}, false
+	}
+	identifier := value[len(urnPrefix)+len(namespace)+1:]
+	if identifier == "" {
+		return URN{}, false
+	}
+	return URN{namespace, identifier}, true
+}
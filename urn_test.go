@@ -0,0 +1,171 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestURNSchemes(t *testing.T) {
+	scenarios := urnSchemesScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error: %q", error.Error())
+		}
+		schemes := magnetURI.URNSchemes()
+		if !reflect.DeepEqual(schemes, scenario.ExpectedSchemes) {
+			t.Errorf("Error on test %q: expected URN schemes: %q; got %q",
+				scenario.Name, scenario.ExpectedSchemes, schemes)
+		}
+		nonURNExactTopics := magnetURI.NonURNExactTopics()
+		if !reflect.DeepEqual(
+			nonURNExactTopics, scenario.ExpectedNonURNExactTopics) {
+			t.Errorf(
+				"Error on test %q: expected non URN exact topics: %v; got %v",
+				scenario.Name, scenario.ExpectedNonURNExactTopics,
+				nonURNExactTopics)
+		}
+	}
+}
+
+type urnSchemesScenario struct {
+	Name                      string
+	RawMagnetURI              string
+	ExpectedSchemes           []string
+	ExpectedNonURNExactTopics []Parameter
+}
+
+var urnSchemesScenarios = []urnSchemesScenario{
+	{
+		Name:            "BitTorrent",
+		RawMagnetURI:    "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a",
+		ExpectedSchemes: []string{"btih"},
+	},
+	{
+		Name: "Multiple schemes",
+		RawMagnetURI: "magnet:?xt.1=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1" +
+			"&xt.2=urn:tree:tiger:7N5OAMRNGMSSEUE3ORHOKWN4WWIQ5X4EBOOTLJY" +
+			"&xt.3=URN:SHA1:7N5OAMRNGMSSEUE3ORHOKWN4WWIQ5X4E" +
+			"&xt.4=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC2",
+		ExpectedSchemes: []string{"ed2k", "tree", "sha1"},
+	},
+	{
+		Name:            "Exact topics that are not URNs",
+		RawMagnetURI:    "magnet:?xt.1=urn:btih:abc&xt.2=http://example.org&xt.3=urn:",
+		ExpectedSchemes: []string{"btih"},
+		ExpectedNonURNExactTopics: []Parameter{
+			{"xt", 2, "http://example.org"},
+			{"xt", 3, "urn:"},
+		},
+	},
+	{
+		Name:         "No exact topics",
+		RawMagnetURI: "magnet:?dn=name",
+	},
+}
+
+func TestHashes(t *testing.T) {
+	magnetURI, error := Parse("magnet:?" +
+		"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a" +
+		"&xt.2=urn:tree:tiger:7N5OAMRNGMSSEUE3ORHOKWN4WWIQ5X4EBOOTLJY" +
+		"&xt.3=URN:SHA1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C" +
+		"&xt.4=http://example.org/file" +
+		"&xt.5=urn:md5:" +
+		"&xt.6=urn:ed2k:354B15E68FB8F36D7CD88FF94116CDC1" +
+		"&dn=name")
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	expectedHashes := []Hash{
+		{"btih", "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+		{"tree:tiger", "7N5OAMRNGMSSEUE3ORHOKWN4WWIQ5X4EBOOTLJY"},
+		{"sha1", "YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+		{"ed2k", "354B15E68FB8F36D7CD88FF94116CDC1"},
+	}
+	hashes := magnetURI.Hashes()
+	if !reflect.DeepEqual(hashes, expectedHashes) {
+		t.Errorf("Expected hashes: %v; got %v", expectedHashes, hashes)
+	}
+}
+
+func TestExactTopicURNs(t *testing.T) {
+	scenarios := exactTopicURNsScenarios
+	for _, scenario := range scenarios {
+		magnetURI := MagnetURI{Parameters: scenario.Parameters}
+		urns, error := magnetURI.ExactTopicURNs()
+		errorMessage := ""
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+		if !reflect.DeepEqual(urns, scenario.ExpectedURNs) {
+			t.Errorf("Error on test %q: expected URNs %v; got %v",
+				scenario.Name, scenario.ExpectedURNs, urns)
+		}
+	}
+}
+
+type exactTopicURNsScenario struct {
+	Name          string
+	Parameters    []Parameter
+	ExpectedURNs  []URN
+	ExpectedError string
+}
+
+var exactTopicURNsScenarios = []exactTopicURNsScenario{
+	{
+		Name:         "No exact topics",
+		Parameters:   []Parameter{Parameter{"dn", 0, "name"}},
+		ExpectedURNs: []URN{},
+	},
+	{
+		Name: "URNs",
+		Parameters: []Parameter{
+			Parameter{"xt", 1, "urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			Parameter{"xt", 2, "URN:SHA1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			Parameter{"xt", 3, "urn:tree:tiger:ABC"},
+		},
+		ExpectedURNs: []URN{
+			URN{"btih", "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"},
+			URN{"sha1", "YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C"},
+			URN{"tree", "tiger:ABC"},
+		},
+	},
+	{
+		Name: "Values that are not URNs",
+		Parameters: []Parameter{
+			Parameter{"xt", 1, "http://example.com/file"},
+			Parameter{"xt", 2, "urn:btih:abc"},
+			Parameter{"xt", 3, "urn:btih:"},
+		},
+		ExpectedURNs: []URN{URN{}, URN{"btih", "abc"}, URN{}},
+		ExpectedError: "Wrong URN at index 0: \"http://example.com/file\"\n" +
+			"Wrong URN at index 2: \"urn:btih:\"",
+	},
+}
+
+func TestURNString(t *testing.T) {
+	urn := URN{"btih", "abc"}
+	if urn.String() != "urn:btih:abc" {
+		t.Errorf("Expected URN \"urn:btih:abc\"; got %q", urn.String())
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxDisplayNameLength is the largest length in bytes of a decoded
+// display name accepted by Validate, the usual limit of a file name.
+const DefaultMaxDisplayNameLength = 255
+
+// ValidationError is returned by Validate, with every problem found in the
+// Magnet URI.
+type ValidationError struct {
+	Errors []error
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		messages = append(messages, e.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the problems found in the Magnet URI, so they can be checked
+// with errors.Is and errors.As.
+func (err *ValidationError) Unwrap() []error {
+	return err.Errors
+}
+
+// Validate checks that the Magnet URI follows the semantic rules of the
+// specification:
+//   - it has at least one exact topic, unless it is a keyword topic search or
+//     a manifest topic;
+//   - the parameters with the same prefix are either all indexed or all
+//     without an index;
+//   - the indices of the parameters with the same prefix are numbered from 1
+//     without gaps;
+//   - no two parameters are identical;
+//   - there are no display names with different values;
+//   - the display names pass ValidateDisplayName once decoded.
+//
+// It returns a *ValidationError with every problem found, or nil.
+func (magnetURI *MagnetURI) Validate() error {
+	if errs := magnetURI.validate(false); len(errs) != 0 {
+		return &ValidationError{errs}
+	}
+	return nil
+}
+
+// ValidateFirst checks the Magnet URI like Validate, but it returns only the
+// first problem found, or nil.
+func (magnetURI *MagnetURI) ValidateFirst() error {
+	if err, ok := magnetURI.Validate().(*ValidationError); ok {
+		return err.Errors[0]
+	}
+	return nil
+}
+
+// validate returns every problem found by the rules of Validate. With
+// requireExactTopic, a keyword topic search or a manifest topic without an
+// exact topic is a problem too.
+func (magnetURI *MagnetURI) validate(requireExactTopic bool) []error {
+	var errs []error
+	errs = append(errs, magnetURI.validateTopics(requireExactTopic)...)
+	errs = append(errs, magnetURI.validateIndices()...)
+	errs = append(errs, magnetURI.validateDuplicates()...)
+	errs = append(errs, magnetURI.validateDisplayNames()...)
+	return errs
+}
+
+func (magnetURI *MagnetURI) validateTopics(requireExactTopic bool) []error {
+	if len(magnetURI.ExactTopics()) != 0 {
+		return nil
+	}
+	if requireExactTopic ||
+		len(magnetURI.KeywordTopics()) == 0 &&
+			len(magnetURI.ManifestTopics()) == 0 {
+		return []error{errors.New("The Magnet URI has no exact topic")}
+	}
+	return nil
+}
+
+func (magnetURI *MagnetURI) validateIndices() []error {
+	var errs []error
+	indices := make(map[Prefix]map[int]bool)
+	nonIndexed := make(map[Prefix]bool)
+	var prefixes []Prefix
+	for _, parameter := range magnetURI.Parameters {
+		if indices[parameter.Prefix] == nil {
+			indices[parameter.Prefix] = make(map[int]bool)
+			prefixes = append(prefixes, parameter.Prefix)
+		}
+		if parameter.Index == 0 {
+			nonIndexed[parameter.Prefix] = true
+		} else {
+			indices[parameter.Prefix][parameter.Index] = true
+		}
+	}
+	for _, prefix := range prefixes {
+		if len(indices[prefix]) != 0 && nonIndexed[prefix] {
+			errs = append(errs, errors.New(
+				fmt.Sprintf(
+					"Parameters with prefix %q mix indexed and "+
+						"non-indexed values", prefix)))
+		}
+		errs = append(errs, validateIndexGaps(prefix, indices[prefix])...)
+	}
+	return errs
+}
+
+// validateIndexGaps checks that the indices of a prefix are numbered 1, 2, ...
+// without gaps.
+func validateIndexGaps(prefix Prefix, indices map[int]bool) []error {
+	var errs []error
+	maxIndex := 0
+	for index := range indices {
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+	for index := 1; index < maxIndex; index++ {
+		if !indices[index] {
+			errs = append(errs, errors.New(
+				fmt.Sprintf(
+					"Parameters with prefix %q are missing index %d",
+					prefix, index)))
+		}
+	}
+	return errs
+}
+
+func (magnetURI *MagnetURI) validateDuplicates() []error {
+	var errs []error
+	for i, parameter := range magnetURI.Parameters {
+		if containsParameter(magnetURI.Parameters[:i], parameter) {
+			errs = append(errs, errors.New(
+				fmt.Sprintf("Duplicate parameter: %q", parameter.String())))
+		}
+	}
+	return errs
+}
+
+func (magnetURI *MagnetURI) validateDisplayNames() []error {
+	var errs []error
+	displayNames := magnetURI.DisplayNames()
+	seen := make(map[string]bool)
+	for _, displayName := range displayNames {
+		if seen[displayName.Value] {
+			continue
+		}
+		seen[displayName.Value] = true
+		if err := validateEncodedDisplayName(displayName); err != nil {
+			errs = append(errs, err)
+		}
+		if displayName.Value != displayNames[0].Value {
+			errs = append(errs, errors.New(
+				fmt.Sprintf("Conflicting display names: %q and %q",
+					displayNames[0].Value, displayName.Value)))
+		}
+	}
+	return errs
+}
+
+// ValidateInfoHashes checks that the BitTorrent info hashes of the exact
+// topics with a "urn:btih:" URN are 40 hex or 32 base32 characters long, to
+// reject truncated or corrupted Magnet URIs. It returns a *ValidationError
+// with every wrong info hash, or nil.
+func (magnetURI *MagnetURI) ValidateInfoHashes() error {
+	if errs := magnetURI.validateInfoHashes(); len(errs) != 0 {
+		return &ValidationError{errs}
+	}
+	return nil
+}
+
+func (magnetURI *MagnetURI) validateInfoHashes() []error {
+	var errs []error
+	for _, infoHash := range magnetURI.infoHashes() {
+		if _, err := decodeInfoHash(infoHash); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"Wrong info hash: %q; %w", infoHash, err))
+		}
+	}
+	return errs
+}
+
+// ParseStrict parses a raw Magnet URI string like Parse, and then checks it
+// with the strictest rules, to reject ambiguous Magnet URIs before publishing
+// them. On top of the rules of Validate:
+//   - it must have at least one exact topic, even if it is a keyword topic
+//     search or a manifest topic;
+//   - the exact topics must be URNs;
+//   - the BitTorrent info hashes must pass ValidateInfoHashes.
+//
+// Parse already rejects the unknown prefixes. The errors of Parse are
+// returned as *ParseError, and the problems found as a *ValidationError with
+// every one of them.
+func ParseStrict(rawMagnetURI string) (MagnetURI, error) {
+	magnetURI, err := Parse(rawMagnetURI)
+	if err != nil {
+		return MagnetURI{}, err
+	}
+	errs := magnetURI.validate(true)
+	for _, exactTopic := range magnetURI.NonURNExactTopics() {
+		errs = append(errs, errors.New(
+			fmt.Sprintf("Exact topic is not a URN: %q", exactTopic.Value)))
+	}
+	errs = append(errs, magnetURI.validateInfoHashes()...)
+	if len(errs) != 0 {
+		return MagnetURI{}, &ValidationError{errs}
+	}
+	return magnetURI, nil
+}
+
+func validateEncodedDisplayName(displayName Parameter) error {
+	name, err := displayName.DecodedValue()
+	if err == nil {
+		err = ValidateDisplayName(name)
+	}
+	if err != nil {
+		return fmt.Errorf("Wrong display name: %q; %w", displayName.Value, err)
+	}
+	return nil
+}
+
+// ValidateDisplayName checks that a decoded display name is safe to suggest
+// as a file name: it must be at most DefaultMaxDisplayNameLength bytes long
+// and it must not have NUL or other control characters.
+func ValidateDisplayName(name string) error {
+	return ValidateDisplayNameLength(name, DefaultMaxDisplayNameLength)
+}
+
+// ValidateDisplayNameLength checks a decoded display name like
+// ValidateDisplayName, but with a maximum of maxLength bytes. Zero or a
+// negative maxLength disables the length check.
+func ValidateDisplayNameLength(name string, maxLength int) error {
+	if maxLength > 0 && len(name) > maxLength {
+		return errors.New(fmt.Sprintf(
+			"Display name too long: %d bytes; the maximum is %d",
+			len(name), maxLength))
+	}
+	for i, r := range name {
+		if unicode.IsControl(r) {
+			return errors.New(fmt.Sprintf(
+				"Control character %q in the display name at byte %d",
+				r, i))
+		}
+	}
+	return nil
+}
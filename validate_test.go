@@ -0,0 +1,395 @@
+// Copyright 2013.
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package magneturi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	scenarios := magnetURIConvertionScenarios
+	for _, scenario := range scenarios {
+		if error := scenario.URIStruct.Validate(); error != nil {
+			t.Errorf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+	}
+}
+
+func TestValidateRepeatedParameters(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 1, "urn:btih:abc"},
+			Parameter{"xt", 2, "urn:btih:def"},
+			Parameter{"dn", 1, "name"},
+			Parameter{"dn", 2, "name"},
+			Parameter{"tr", 1, "http://tracker1.example/announce"},
+			Parameter{"tr", 2, "http://tracker2.example/announce"},
+			Parameter{"kt", 1, "martin"},
+			Parameter{"kt", 2, "king"},
+		},
+	}
+	if error := magnetURI.Validate(); error != nil {
+		t.Errorf("There was an error: %q", error.Error())
+	}
+}
+
+func TestValidateWithErrors(t *testing.T) {
+	scenarios := validateWithErrorsScenarios
+	for _, scenario := range scenarios {
+		error := scenario.URIStruct.Validate()
+		if error == nil {
+			t.Fatalf("No error was returned on %q test.", scenario.Name)
+		}
+		if error.Error() != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, error.Error())
+		}
+		validationError, ok := error.(*ValidationError)
+		if !ok {
+			t.Fatalf("Error on test %q: expected a ValidationError; got %#v",
+				scenario.Name, error)
+		}
+		if len(validationError.Errors) != scenario.ExpectedCount {
+			t.Errorf("Error on test %q: expected %d errors; got %d",
+				scenario.Name, scenario.ExpectedCount,
+				len(validationError.Errors))
+		}
+	}
+}
+
+func TestValidateFirst(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"xt", 0, "urn:btih:abc"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	if error := magnetURI.ValidateFirst(); error != nil {
+		t.Errorf("There was an error: %q", error.Error())
+	}
+}
+
+func TestValidateFirstWithErrors(t *testing.T) {
+	magnetURI := MagnetURI{
+		Parameters: []Parameter{
+			Parameter{"dn", 0, "name"},
+			Parameter{"dn", 0, "name"},
+		},
+	}
+	error := magnetURI.ValidateFirst()
+	if error == nil {
+		t.Fatal("No error was returned.")
+	}
+	expectedError := "The Magnet URI has no exact topic"
+	if error.Error() != expectedError {
+		t.Errorf("Expected error message: %q; got %q",
+			expectedError, error.Error())
+	}
+}
+
+type validateWithErrorsScenario struct {
+	Name          string
+	URIStruct     MagnetURI
+	ExpectedError string
+	ExpectedCount int
+}
+
+var validateWithErrorsScenarios = []validateWithErrorsScenario{
+	{
+		Name: "No exact topic",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"dn", 0, "name"},
+				Parameter{"tr", 0, "http://tracker.example/announce"},
+			},
+		},
+		ExpectedError: "The Magnet URI has no exact topic",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Mixed indices",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"xt", 1, "urn:btih:def"},
+			},
+		},
+		ExpectedError: "Parameters with prefix \"xt\" mix indexed and " +
+			"non-indexed values",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Index gap",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 1, "urn:btih:abc"},
+				Parameter{"xt", 3, "urn:btih:def"},
+			},
+		},
+		ExpectedError: "Parameters with prefix \"xt\" are missing index 2",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Index not starting at 1",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"tr", 3, "http://tracker3.example/announce"},
+				Parameter{"tr", 2, "http://tracker2.example/announce"},
+			},
+		},
+		ExpectedError: "Parameters with prefix \"tr\" are missing index 1",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Duplicate parameters",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"xt", 0, "urn:btih:abc"},
+			},
+		},
+		ExpectedError: "Duplicate parameter: \"xt=urn:btih:abc\"",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Conflicting display names",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 1, "urn:btih:abc"},
+				Parameter{"dn", 1, "first"},
+				Parameter{"xt", 2, "urn:btih:def"},
+				Parameter{"dn", 2, "second"},
+				Parameter{"dn", 3, "first"},
+			},
+		},
+		ExpectedError: "Conflicting display names: \"first\" and \"second\"",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Display name with a control character",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"xt", 0, "urn:btih:abc"},
+				Parameter{"dn", 0, "file%00name"},
+			},
+		},
+		ExpectedError: "Wrong display name: \"file%00name\"; " +
+			"Control character '\\x00' in the display name at byte 4",
+		ExpectedCount: 1,
+	},
+	{
+		Name: "Several problems",
+		URIStruct: MagnetURI{
+			Parameters: []Parameter{
+				Parameter{"tr", 0, "http://tracker.example/announce"},
+				Parameter{"tr", 1, "http://tracker.example/announce"},
+				Parameter{"tr", 1, "http://tracker.example/announce"},
+			},
+		},
+		ExpectedError: "The Magnet URI has no exact topic; " +
+			"Parameters with prefix \"tr\" mix indexed and " +
+			"non-indexed values; " +
+			"Duplicate parameter: \"tr.1=http://tracker.example/announce\"",
+		ExpectedCount: 3,
+	},
+}
+
+func TestValidateInfoHashes(t *testing.T) {
+	scenarios := validateInfoHashesScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := Parse(scenario.RawMagnetURI)
+		if error != nil {
+			t.Fatalf("There was an error on test %q: %q",
+				scenario.Name, error.Error())
+		}
+		errorMessage := ""
+		if error := magnetURI.ValidateInfoHashes(); error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+		if valid := magnetURI.InfoHashValid(); valid != scenario.ExpectedValid {
+			t.Errorf("Error on test %q: expected valid %t; got %t",
+				scenario.Name, scenario.ExpectedValid, valid)
+		}
+	}
+}
+
+type validateInfoHashesScenario struct {
+	Name          string
+	RawMagnetURI  string
+	ExpectedError string
+	ExpectedValid bool
+}
+
+var validateInfoHashesScenarios = []validateInfoHashesScenario{
+	{
+		Name: "Hex and base32 info hashes",
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"xt.2=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK",
+		ExpectedValid: true,
+	},
+	{
+		Name: "Truncated info hash",
+		RawMagnetURI: "magnet:?" +
+			"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"xt.2=urn:btih:c12fe1c06bba254a9dc9",
+		ExpectedError: "Wrong info hash: \"c12fe1c06bba254a9dc9\"; " +
+			"Wrong info hash length: 20; expected 40 hex or 32 base32 " +
+			"characters",
+		ExpectedValid: false,
+	},
+	{
+		Name:          "No info hash",
+		RawMagnetURI:  "magnet:?xt=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C",
+		ExpectedValid: false,
+	},
+}
+
+func TestValidateDisplayName(t *testing.T) {
+	scenarios := validateDisplayNameScenarios
+	for _, scenario := range scenarios {
+		errorMessage := ""
+		error := ValidateDisplayName(scenario.Name)
+		if error != nil {
+			errorMessage = error.Error()
+		}
+		if errorMessage != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, errorMessage)
+		}
+	}
+}
+
+type validateDisplayNameScenario struct {
+	Name          string
+	ExpectedError string
+}
+
+var validateDisplayNameScenarios = []validateDisplayNameScenario{
+	{
+		Name: "Some file name.txt",
+	},
+	{
+		Name: "Ünïcödé ファイル",
+	},
+	{
+		Name:          "new\nline",
+		ExpectedError: "Control character '\\n' in the display name at byte 3",
+	},
+	{
+		Name:          "delete\x7f",
+		ExpectedError: "Control character '\\x7f' in the display name at byte 6",
+	},
+	{
+		Name: strings.Repeat("a", 256),
+		ExpectedError: "Display name too long: 256 bytes; " +
+			"the maximum is 255",
+	},
+}
+
+func TestValidateDisplayNameLength(t *testing.T) {
+	error := ValidateDisplayNameLength(strings.Repeat("a", 1000), 0)
+	if error != nil {
+		t.Errorf("There was an error: %q", error.Error())
+	}
+	error = ValidateDisplayNameLength("name", 3)
+	expectedErrorMessage := "Display name too long: 4 bytes; the maximum is 3"
+	if error == nil || error.Error() != expectedErrorMessage {
+		t.Errorf("Expected error message %q; got %v",
+			expectedErrorMessage, error)
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	rawMagnetURI := "magnet:?" +
+		"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+		"xt.2=urn:sha1:YNCKHTQCWBTRNJIV4WNAE52SJUQCZO5C&" +
+		"dn=name&tr=http://tracker.example/announce"
+	magnetURI, error := ParseStrict(rawMagnetURI)
+	if error != nil {
+		t.Fatalf("There was an error: %q", error.Error())
+	}
+	if len(magnetURI.Parameters) != 4 {
+		t.Errorf("Expected 4 parameters; got %v", magnetURI.Parameters)
+	}
+}
+
+func TestParseStrictWithErrors(t *testing.T) {
+	scenarios := parseStrictWithErrorsScenarios
+	for _, scenario := range scenarios {
+		magnetURI, error := ParseStrict(scenario.RawMagnetURI)
+		if error == nil {
+			t.Fatalf("No error was returned on %q test.", scenario.Name)
+		}
+		if error.Error() != scenario.ExpectedError {
+			t.Errorf(
+				"Error on test %q: Expected error message: %q; got %q",
+				scenario.Name, scenario.ExpectedError, error.Error())
+		}
+		if !magnetURI.IsEmpty() {
+			t.Errorf("Error on test %q: expected an empty Magnet URI; got %v",
+				scenario.Name, magnetURI)
+		}
+	}
+}
+
+type parseStrictWithErrorsScenario struct {
+	Name          string
+	RawMagnetURI  string
+	ExpectedError string
+}
+
+var parseStrictWithErrorsScenarios = []parseStrictWithErrorsScenario{
+	{
+		Name:          "Unknown prefix",
+		RawMagnetURI:  "magnet:?xt=urn:btih:abc&unknown=value",
+		ExpectedError: "Unknown parameter prefix: \"unknown\"",
+	},
+	{
+		Name:          "Keyword topic without exact topic",
+		RawMagnetURI:  "magnet:?kt=martin+luther+king+mp3",
+		ExpectedError: "The Magnet URI has no exact topic",
+	},
+	{
+		Name: "Exact topic that is not a URN",
+		RawMagnetURI: "magnet:?" +
+			"xt=http://example.com/file",
+		ExpectedError: "Exact topic is not a URN: \"http://example.com/file\"",
+	},
+	{
+		Name: "Several problems",
+		RawMagnetURI: "magnet:?" +
+			"xt=urn:btih:abc&" +
+			"xt.1=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&" +
+			"tr=http://tracker.example/announce&" +
+			"tr=http://tracker.example/announce",
+		ExpectedError: "Parameters with prefix \"xt\" mix indexed and " +
+			"non-indexed values; " +
+			"Duplicate parameter: \"tr=http://tracker.example/announce\"; " +
+			"Wrong info hash: \"abc\"; Wrong info hash length: 3; " +
+			"expected 40 hex or 32 base32 characters",
+	},
+}